@@ -1,21 +1,30 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
 	"github.com/s/onlineCourse/internal/auth"
 	"github.com/s/onlineCourse/internal/database"
 	"github.com/s/onlineCourse/internal/handlers"
 	"github.com/s/onlineCourse/internal/handlers/admin"
 	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/jobs"
+	"github.com/s/onlineCourse/internal/mail"
 	"github.com/s/onlineCourse/internal/middleware"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/realtime"
+	"github.com/s/onlineCourse/internal/session"
+	"github.com/s/onlineCourse/internal/storage"
+	"github.com/s/onlineCourse/internal/webhook"
 )
 
 func main() {
@@ -41,30 +50,90 @@ func main() {
 		log.Printf("Warning: seed error: %v", err)
 	}
 
-	clientId := os.Getenv("GOOGLE_CLIENT_ID")
-	clientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
-	redirectURL := os.Getenv("GOOGLE_REDIRECT_URL")
+	// Внешние провайдеры входа: Google (GOOGLE_*) и OIDC_PROVIDERS.
+	// Без них остаются вход по паролю и по ссылке из письма.
+	providerConfigs, errs := auth.ProvidersFromEnv(handlers.SiteBaseURL())
+	for _, err := range errs {
+		log.Printf("Warning: OIDC provider skipped: %v", err)
+	}
+	if len(providerConfigs) == 0 {
+		log.Println("Warning: no external login providers configured (GOOGLE_*, OIDC_PROVIDERS).")
+	}
+	providers := auth.NewRegistry(providerConfigs...)
 
-	if clientId == "" || clientSecret == "" || redirectURL == "" {
-		log.Fatal("Error: GOOGLE_* environment variables are not set")
+	// APP_ENV=production: только HTTPS-cookie и обязательный свой SESSION_KEY
+	production := os.Getenv("APP_ENV") == "production"
+	sessionKey := os.Getenv("SESSION_KEY")
+	if weakSecret(sessionKey, defaultSessionKey, "your_random_session_key_here") {
+		if production {
+			log.Fatal("SESSION_KEY must be a random secret of at least 32 characters in production.")
+		}
+		if sessionKey == "" {
+			sessionKey = defaultSessionKey
+		}
+		log.Println("Warning: SESSION_KEY not set or weak, using it outside production only.")
 	}
+	store := session.NewStore(db, []byte(sessionKey))
 
-	oauthConfig := auth.InitGoogleOAuthConfig(clientId, clientSecret, redirectURL)
+	// Приватные ключи подписи сертификатов и секреты TOTP лежат в базе зашифрованными
+	signingSecret := os.Getenv("SIGNING_KEY_SECRET")
+	if weakSecret(signingSecret, defaultSigningSecret, "your_random_signing_secret_here") {
+		if production {
+			log.Fatal("SIGNING_KEY_SECRET must be a random secret of at least 32 characters in production.")
+		}
+		if signingSecret == "" {
+			signingSecret = defaultSigningSecret
+		}
+		log.Println("Warning: SIGNING_KEY_SECRET not set or weak, using it outside production only.")
+	}
+	if err := storage.SetSigningSecret(signingSecret); err != nil {
+		log.Fatal("Signing secret error:", err)
+	}
+	store.Options.Secure = production
 
-	sessionKey := os.Getenv("SESSION_KEY")
-	if sessionKey == "" {
-		sessionKey = "super-secret-default-key"
-		log.Println("Warning: SESSION_KEY not set, using default.")
+	h := handlers.NewHandler(db, store, providers)
+
+	// Живые обновления: при нескольких инстансах события идут через Postgres LISTEN/NOTIFY
+	if os.Getenv("REALTIME_BACKEND") == "postgres" {
+		broker, err := realtime.NewPostgresBroker(context.Background(), database.DSN(), db)
+		if err != nil {
+			log.Fatal("Realtime LISTEN error:", err)
+		}
+		h.Events = broker
 	}
-	store := sessions.NewCookieStore([]byte(sessionKey))
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   86400 * 7,
-		HttpOnly: true,
-		Secure:   false,
+	storage.OnNotify(h.PublishNotification)
+
+	// Письма о решениях по заявкам, проверке курсов и сертификатах
+	mailer := &mail.Notifier{DB: db, Sender: mail.NewSender(mail.ConfigFromEnv()), BaseURL: handlers.SiteBaseURL()}
+	storage.OnNotify(mailer.HandleNotification)
+	h.Mailer = mailer.Sender
+
+	// Фоновые задачи: очередь в Postgres, воркеры на каждом инстансе
+	workers, _ := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if workers == 0 {
+		workers = 4
 	}
+	pool := jobs.NewPool(db, workers)
+	h.RegisterJobs(pool)
+	pool.Handle(models.JobNotificationEmail, mailer.RunJob)
+	pool.Handle(models.JobDeliverWebhooks, webhook.NewDispatcher(db).RunJob)
+	pool.Handle(models.JobExpireEnrollments, func(ctx context.Context, payload json.RawMessage) error {
+		// Истечение срока доступа освобождает места для листа ожидания
+		storage.ExpireEnrollments(db)
+		return nil
+	})
+	pool.Handle(models.JobCleanupJobs, func(ctx context.Context, payload json.RawMessage) error {
+		return storage.CleanupJobs(db, 24*time.Hour)
+	})
+	pool.Every(models.JobExpireEnrollments, time.Hour)
+	pool.Every(models.JobDeliverWebhooks, time.Minute) // повторы неудачных доставок
+	pool.Handle(models.JobCleanupSessions, func(ctx context.Context, payload json.RawMessage) error {
+		return storage.CleanupSessions(db)
+	})
+	pool.Every(models.JobCleanupJobs, time.Hour)
+	pool.Every(models.JobCleanupSessions, time.Hour)
+	pool.Start(context.Background())
 
-	h := handlers.NewHandler(db, store, oauthConfig)
 	adminService := admin.Service{Handler: *h}
 
 	adminMiddleware := middleware.RequiredRole(h, models.RoleAdmin)
@@ -82,13 +151,52 @@ func main() {
 	r.HandleFunc("/api/home", h.GetHomeDataAPI).Methods("GET")
 	r.HandleFunc("/api/language", h.HandleSetLanguage).Methods("POST")
 
-	r.HandleFunc("/auth/google/login", h.HandleGoogleLogin).Methods("GET")
-	r.HandleFunc("/auth/google/callback", h.HandleGoogleCallback).Methods("GET")
+	r.HandleFunc("/auth/{provider}/login", h.HandleOIDCLogin).Methods("GET")
+	r.HandleFunc("/auth/{provider}/callback", h.HandleOIDCCallback).Methods("GET")
 	r.HandleFunc("/logout", h.HandleLogout).Methods("GET", "POST")
 
+	// Local accounts: password, e-mail verification, reset and magic link
+	r.HandleFunc("/login", h.HandleAuthPage).Methods("GET")
+	r.HandleFunc("/register", h.HandleAuthPage).Methods("GET")
+	r.HandleFunc("/forgot-password", h.HandleAuthPage).Methods("GET")
+	r.HandleFunc("/reset-password", h.HandleAuthPage).Methods("GET")
+	r.HandleFunc("/auth/magic", h.HandleAuthPage).Methods("GET")
+	r.HandleFunc("/login/2fa", h.HandleAuthPage).Methods("GET")
+	r.HandleFunc("/auth/verify", h.HandleAuthPage).Methods("GET")
+	r.HandleFunc("/api/auth/register", h.RegisterAPI).Methods("POST")
+	r.HandleFunc("/api/auth/login", h.LoginAPI).Methods("POST")
+	r.HandleFunc("/api/auth/verify/resend", h.ResendVerificationAPI).Methods("POST")
+	r.HandleFunc("/api/auth/password/forgot", h.ForgotPasswordAPI).Methods("POST")
+	r.HandleFunc("/api/auth/password/reset", h.ResetPasswordAPI).Methods("POST")
+	r.HandleFunc("/api/auth/magic-link", h.MagicLinkAPI).Methods("POST")
+	r.HandleFunc("/api/auth/magic", h.MagicLoginAPI).Methods("POST")
+	r.HandleFunc("/api/auth/verify", h.VerifyEmailAPI).Methods("POST")
+	r.HandleFunc("/api/auth/2fa", h.SecondFactorAPI).Methods("POST")
+	r.HandleFunc("/api/account/identities", h.ListIdentitiesAPI).Methods("GET")
+	r.HandleFunc("/api/account/sessions", userMiddleware(h.ListSessionsAPI)).Methods("GET")
+	r.HandleFunc("/api/account/sessions/{id:[0-9]+}", userMiddleware(h.RevokeSessionAPI)).Methods("DELETE")
+	r.HandleFunc("/api/account/sessions/logout-all", userMiddleware(h.LogoutEverywhereAPI)).Methods("POST")
+	r.HandleFunc("/api/account/tokens", userMiddleware(h.ListAPITokensAPI)).Methods("GET")
+	r.HandleFunc("/api/account/tokens", userMiddleware(h.CreateAPITokenAPI)).Methods("POST")
+	r.HandleFunc("/api/account/tokens/{id:[0-9]+}", userMiddleware(h.RevokeAPITokenAPI)).Methods("DELETE")
+	r.HandleFunc("/api/account/2fa", userMiddleware(h.TwoFactorStatusAPI)).Methods("GET")
+	r.HandleFunc("/api/account/2fa/setup", userMiddleware(h.SetupTwoFactorAPI)).Methods("POST")
+	r.HandleFunc("/api/account/2fa/enable", userMiddleware(h.EnableTwoFactorAPI)).Methods("POST")
+	r.HandleFunc("/api/account/2fa/disable", userMiddleware(h.DisableTwoFactorAPI)).Methods("POST")
+	r.HandleFunc("/api/account/2fa/recovery-codes", userMiddleware(h.RegenerateRecoveryCodesAPI)).Methods("POST")
+
 	r.HandleFunc("/personal", h.HandleProfile).Methods("GET")
 	r.HandleFunc("/cabinet", userMiddleware(h.HandleCabinet)).Methods("GET")
 	r.HandleFunc("/certificate/{code}", h.HandleVerifyCertificate).Methods("GET")
+	r.HandleFunc("/certificate/{code}/pdf", h.HandleCertificatePDF).Methods("GET")
+	r.HandleFunc("/certificate/{code}/signed", h.HandleSignedCertificate).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", h.GetCertificateKeysAPI).Methods("GET")
+	r.HandleFunc("/api/certificates/keys", h.GetCertificateKeysAPI).Methods("GET")
+	r.HandleFunc("/api/certificates/verify", h.VerifyCertificateAPI).Methods("POST")
+	r.HandleFunc("/badges/issuer", h.HandleBadgeIssuer).Methods("GET")
+	r.HandleFunc("/badges/achievements/{id:[0-9]+}", h.HandleBadgeAchievement).Methods("GET")
+	r.HandleFunc("/badges/credentials/{code}", h.HandleBadgeCredential).Methods("GET")
+	r.HandleFunc("/badges/credentials/{code}/jwt", h.HandleBadgeCredentialJWT).Methods("GET")
 
 	// Admin pages
 	r.HandleFunc("/admin/dashboard", adminMiddleware(adminService.HandleAdminPage)).Methods("GET")
@@ -109,11 +217,25 @@ func main() {
 	r.HandleFunc("/api/lessons/{id}/content", adminMiddleware(adminService.UpdateLessonContentAPI)).Methods("PUT")
 	r.HandleFunc("/admin/enrollments", adminMiddleware(adminService.HandleEnrollmentsPage)).Methods("GET")
 	r.HandleFunc("/api/admin/enrollments", adminMiddleware(adminService.GetEnrollmentsAPI)).Methods("GET")
+	r.HandleFunc("/api/admin/enrollments/{id}", adminMiddleware(adminService.GetEnrollmentAPI)).Methods("GET")
 	r.HandleFunc("/api/admin/enrollments/{id}", adminMiddleware(adminService.UpdateEnrollmentStatusAPI)).Methods("PUT")
+	r.HandleFunc("/api/admin/courses/{id:[0-9]+}/enrollments/import", adminMiddleware(adminService.ImportEnrollmentsAPI)).Methods("POST")
+	r.HandleFunc("/api/admin/signing-keys", adminMiddleware(adminService.GetSigningKeysAPI)).Methods("GET")
+	r.HandleFunc("/api/admin/signing-keys/rotate", adminMiddleware(adminService.RotateSigningKeyAPI)).Methods("POST")
+	r.HandleFunc("/api/admin/signing-keys/{kid}/revoke", adminMiddleware(adminService.RevokeSigningKeyAPI)).Methods("POST")
+	r.HandleFunc("/api/admin/certificates", adminMiddleware(adminService.GetCertificatesAPI)).Methods("GET")
+	r.HandleFunc("/api/admin/certificates/{id:[0-9]+}/{action:revoke|reissue}", adminMiddleware(adminService.ChangeCertificateAPI)).Methods("POST")
 
 	// Student
 	r.HandleFunc("/api/courses/{id}/structure", adminService.GetCourseStructure).Methods("GET")
+	r.HandleFunc("/api/courses/{id:[0-9]+}/application-form", h.GetApplicationFormAPI).Methods("GET")
+	r.HandleFunc("/api/courses/{id:[0-9]+}/application-form/upload", userMiddleware(h.UploadApplicationFileAPI)).Methods("POST")
 	r.HandleFunc("/api/enroll", userMiddleware(adminService.SubmitEnrollment)).Methods("POST")
+	r.HandleFunc("/api/enroll/{id:[0-9]+}", userMiddleware(h.GetMyEnrollmentAPI)).Methods("GET")
+	r.HandleFunc("/api/enroll/{id:[0-9]+}/unenroll", userMiddleware(h.UnenrollAPI)).Methods("POST")
+	r.HandleFunc("/api/enroll/{id:[0-9]+}/rerequest", userMiddleware(h.RerequestEnrollmentAPI)).Methods("POST")
+	r.HandleFunc("/invite/{token}", h.HandleInviteLink).Methods("GET")
+	r.HandleFunc("/api/invites/redeem", userMiddleware(h.RedeemAccessCodeAPI)).Methods("POST")
 	r.HandleFunc("/my-courses", userMiddleware(h.HandleStudentDashboard)).Methods("GET")
 	r.HandleFunc("/course/{id:[0-9]+}/learn", h.HandleCourseLearn).Methods("GET")
 	r.HandleFunc("/api/course/{id:[0-9]+}/lesson/{lesson_id:[0-9]+}/quiz", userMiddleware(h.SaveQuizAttemptAPI)).Methods("POST")
@@ -140,8 +262,27 @@ func main() {
 	r.HandleFunc("/api/studio/lessons/{id:[0-9]+}", userMiddleware(h.StudioGetLessonAPI)).Methods("GET")
 	r.HandleFunc("/api/studio/lessons/{id:[0-9]+}/content", userMiddleware(h.StudioUpdateLessonContentAPI)).Methods("PUT")
 	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/enrollments", userMiddleware(h.StudioGetCourseEnrollmentsAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/enrollments/{id:[0-9]+}", userMiddleware(h.StudioGetEnrollmentAPI)).Methods("GET")
 	r.HandleFunc("/api/studio/enrollments/{id:[0-9]+}", userMiddleware(h.StudioUpdateEnrollmentAPI)).Methods("PUT")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/enrollments/import", userMiddleware(h.StudioImportEnrollmentsAPI)).Methods("POST")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/enrollment-rules", userMiddleware(h.StudioGetEnrollmentRulesAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/enrollment-rules", userMiddleware(h.StudioUpdateEnrollmentRulesAPI)).Methods("PUT")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/application-form", userMiddleware(h.StudioGetApplicationFormAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/application-form", userMiddleware(h.StudioUpdateApplicationFormAPI)).Methods("PUT")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/certificate-template", userMiddleware(h.StudioGetCertificateTemplateAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/certificate-template", userMiddleware(h.StudioUpdateCertificateTemplateAPI)).Methods("PUT")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/certificate-template/preview", userMiddleware(h.StudioPreviewCertificateAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/certificates", userMiddleware(h.StudioGetCourseCertificatesAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/assistants", userMiddleware(h.StudioGetAssistantsAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/assistants", userMiddleware(h.StudioAddAssistantAPI)).Methods("POST")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/assistants/{user_id:[0-9]+}", userMiddleware(h.StudioRemoveAssistantAPI)).Methods("DELETE")
+	r.HandleFunc("/api/studio/questions/unanswered", userMiddleware(h.StudioUnansweredQuestionsAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/certificates/{cert_id:[0-9]+}/{action:revoke|reissue}", userMiddleware(h.StudioChangeCertificateAPI)).Methods("POST")
 	r.HandleFunc("/api/studio/upload", userMiddleware(h.StudioUploadFileAPI)).Methods("POST")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/invites", userMiddleware(h.StudioGetInvitesAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/courses/{id:[0-9]+}/invites", userMiddleware(h.StudioCreateInviteAPI)).Methods("POST")
+	r.HandleFunc("/api/studio/invites/{id:[0-9]+}", userMiddleware(h.StudioRevokeInviteAPI)).Methods("DELETE")
+	r.HandleFunc("/api/studio/invites/{id:[0-9]+}/redemptions", userMiddleware(h.StudioGetInviteRedemptionsAPI)).Methods("GET")
 
 	// Admin — course review requests
 	r.HandleFunc("/admin/course-requests", adminMiddleware(adminService.HandleCourseRequestsPage)).Methods("GET")
@@ -151,16 +292,56 @@ func main() {
 	// Admin — user management
 	r.HandleFunc("/api/admin/users", adminMiddleware(adminService.GetUsersAPI)).Methods("GET")
 	r.HandleFunc("/api/admin/users/{id:[0-9]+}/role", adminMiddleware(adminService.UpdateUserRoleAPI)).Methods("PUT")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/impersonate", adminMiddleware(h.StartImpersonationAPI)).Methods("POST")
+	r.HandleFunc("/api/impersonation", h.ImpersonationStatusAPI).Methods("GET")
+	r.HandleFunc("/api/impersonation/stop", h.StopImpersonationAPI).Methods("POST")
 
 	// Comments & Reviews
 	r.HandleFunc("/api/lessons/{id}/comments", userMiddleware(h.AddCommentAPI)).Methods("POST")
 	r.HandleFunc("/api/lessons/{id}/comments", h.GetCommentsAPI).Methods("GET")
 	r.HandleFunc("/api/courses/{id}/reviews", userMiddleware(h.AddReviewAPI)).Methods("POST")
 	r.HandleFunc("/api/courses/{id}/reviews", h.GetReviewsAPI).Methods("GET")
+	r.HandleFunc("/api/reviews/{id:[0-9]+}/helpful", userMiddleware(h.ReviewHelpfulAPI)).Methods("POST")
+	r.HandleFunc("/api/reviews/{id:[0-9]+}/response", userMiddleware(h.RespondReviewAPI)).Methods("PUT")
 
 	// Course comments
 	r.HandleFunc("/api/courses/{id}/comments", userMiddleware(h.AddCourseCommentAPI)).Methods("POST")
 	r.HandleFunc("/api/courses/{id}/comments", h.GetCourseCommentsAPI).Methods("GET")
+	r.HandleFunc("/api/comments/{id:[0-9]+}", userMiddleware(h.UpdateCommentAPI)).Methods("PUT")
+	r.HandleFunc("/api/comments/{id:[0-9]+}", userMiddleware(h.DeleteCommentAPI)).Methods("DELETE")
+	r.HandleFunc("/api/{type:comment|review}s/{id:[0-9]+}/report", userMiddleware(h.ReportContentAPI)).Methods("POST")
+
+	// Lesson Q&A
+	r.HandleFunc("/api/lessons/{id:[0-9]+}/questions", h.GetLessonQuestionsAPI).Methods("GET")
+	r.HandleFunc("/api/lessons/{id:[0-9]+}/questions", userMiddleware(h.AskQuestionAPI)).Methods("POST")
+	r.HandleFunc("/api/courses/{id:[0-9]+}/questions", h.SearchCourseQuestionsAPI).Methods("GET")
+	r.HandleFunc("/api/questions/{id:[0-9]+}", h.GetQuestionAPI).Methods("GET")
+	r.HandleFunc("/api/questions/{id:[0-9]+}/answers", userMiddleware(h.AnswerQuestionAPI)).Methods("POST")
+	r.HandleFunc("/api/questions/{id:[0-9]+}/accept", userMiddleware(h.AcceptAnswerAPI)).Methods("PUT")
+	r.HandleFunc("/api/{type:question|answer}s/{id:[0-9]+}/vote", userMiddleware(h.VoteQAAPI)).Methods("POST")
+
+	// Webhooks (author-owned; platform-wide with ?scope=platform for admins)
+	r.HandleFunc("/studio/webhooks", userMiddleware(h.HandleWebhooksPage)).Methods("GET")
+	r.HandleFunc("/api/studio/webhooks", userMiddleware(h.ListWebhooksAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/webhooks", userMiddleware(h.CreateWebhookAPI)).Methods("POST")
+	r.HandleFunc("/api/studio/webhooks/{id:[0-9]+}", userMiddleware(h.UpdateWebhookAPI)).Methods("PUT")
+	r.HandleFunc("/api/studio/webhooks/{id:[0-9]+}", userMiddleware(h.DeleteWebhookAPI)).Methods("DELETE")
+	r.HandleFunc("/api/studio/webhooks/{id:[0-9]+}/deliveries", userMiddleware(h.ListWebhookDeliveriesAPI)).Methods("GET")
+	r.HandleFunc("/api/studio/webhooks/deliveries/{id:[0-9]+}/redeliver", userMiddleware(h.RedeliverWebhookAPI)).Methods("POST")
+
+	// Live updates (Server-Sent Events)
+	r.HandleFunc("/api/events", userMiddleware(h.EventsStream)).Methods("GET")
+
+	// E-mail preferences and one-click unsubscribe
+	r.HandleFunc("/api/email-preferences", userMiddleware(h.GetEmailPreferencesAPI)).Methods("GET")
+	r.HandleFunc("/api/email-preferences", userMiddleware(h.UpdateEmailPreferencesAPI)).Methods("PUT")
+	r.HandleFunc("/email/unsubscribe/{token}", h.HandleEmailUnsubscribe).Methods("GET", "POST")
+
+	// Notifications
+	r.HandleFunc("/api/notifications", userMiddleware(h.GetNotificationsAPI)).Methods("GET")
+	r.HandleFunc("/api/notifications/unread-count", userMiddleware(h.UnreadNotificationsAPI)).Methods("GET")
+	r.HandleFunc("/api/notifications/read-all", userMiddleware(h.MarkNotificationsReadAPI)).Methods("POST")
+	r.HandleFunc("/api/notifications/{id:[0-9]+}/read", userMiddleware(h.MarkNotificationsReadAPI)).Methods("POST")
 
 	// Reactions (like/dislike)
 	r.HandleFunc("/api/courses/{id}/react", userMiddleware(h.ReactCourseAPI)).Methods("POST")
@@ -172,11 +353,26 @@ func main() {
 	r.HandleFunc("/admin/journal", adminMiddleware(adminService.HandleJournalPage)).Methods("GET")
 	r.HandleFunc("/api/admin/journal", adminMiddleware(adminService.GetJournalAPI)).Methods("GET")
 
+	// Admin — moderation
+	r.HandleFunc("/admin/moderation", adminMiddleware(adminService.HandleModerationPage)).Methods("GET")
+	r.HandleFunc("/api/admin/moderation", adminMiddleware(adminService.GetModerationQueueAPI)).Methods("GET")
+	r.HandleFunc("/api/admin/moderation/{type:comment|review}/{id:[0-9]+}/{action:hide|restore|delete}", adminMiddleware(adminService.ModerateAPI)).Methods("POST")
+	r.HandleFunc("/api/admin/banned-words/{lang}", adminMiddleware(adminService.GetBannedWordsAPI)).Methods("GET")
+	r.HandleFunc("/api/admin/banned-words/{lang}", adminMiddleware(adminService.SetBannedWordsAPI)).Methods("PUT")
+	r.HandleFunc("/api/admin/restrictions", adminMiddleware(adminService.GetRestrictionsAPI)).Methods("GET")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/restriction", adminMiddleware(adminService.RestrictUserAPI)).Methods("PUT")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/restriction", adminMiddleware(adminService.LiftRestrictionAPI)).Methods("DELETE")
+
+	// Admin — background jobs
+	r.HandleFunc("/admin/jobs", adminMiddleware(adminService.HandleJobsPage)).Methods("GET")
+	r.HandleFunc("/api/admin/jobs", adminMiddleware(adminService.GetJobsAPI)).Methods("GET")
+	r.HandleFunc("/api/admin/jobs/{id:[0-9]+}/retry", adminMiddleware(adminService.RetryJobAPI)).Methods("POST")
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	corsHandler := corsMiddleware(r)
+	corsHandler := corsMiddleware(middleware.APITokens(h)(middleware.CSRF(h)(middleware.Impersonation(h)(r))))
 	fmt.Printf("Server started: http://localhost:%s\n", port)
 	log.Fatal(http.ListenAndServe(":"+port, corsHandler))
 }
@@ -185,7 +381,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+handlers.CSRFHeader)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -195,3 +391,24 @@ func corsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// defaultSessionKey signs sessions in development when SESSION_KEY is unset.
+const defaultSessionKey = "super-secret-default-key"
+
+// defaultSigningSecret encrypts signing keys in development when
+// SIGNING_KEY_SECRET is unset.
+const defaultSigningSecret = "insecure-default-signing-secret"
+
+// weakSecret reports a missing, default or placeholder (.env.example)
+// secret, or one too short to be safe.
+func weakSecret(secret string, known ...string) bool {
+	if secret == "" {
+		return true
+	}
+	for _, k := range known {
+		if secret == k {
+			return true
+		}
+	}
+	return len(secret) < 32
+}
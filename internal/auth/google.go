@@ -1,18 +1,18 @@
 // internal/auth/google.go
 package auth
 
-import "golang.org/x/oauth2"
-import "golang.org/x/oauth2/google"
+// GoogleName is the provider name of Google; its subject is the Google ID
+// stored in models.User.GoogleID by earlier versions.
+const GoogleName = "google"
 
-func InitGoogleOAuthConfig(clientID, clientSecret, redirectURL string) *oauth2.Config {
-	return &oauth2.Config{
+// GoogleConfig is Google as an OpenID Connect provider.
+func GoogleConfig(clientID, clientSecret, redirectURL string) ProviderConfig {
+	return ProviderConfig{
+		Name:         GoogleName,
+		DisplayName:  "Google",
+		Issuer:       "https://accounts.google.com",
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		RedirectURL:  redirectURL,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
 	}
 }
@@ -0,0 +1,229 @@
+// Package auth signs users in with external OpenID Connect providers:
+// Google and any corporate IdP (Keycloak, Azure AD, …) that supports
+// discovery.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderConfig describes one identity provider.
+type ProviderConfig struct {
+	Name         string // в URL: /auth/{name}/login
+	DisplayName  string // на кнопке входа
+	Issuer       string // discovery: {issuer}/.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// TrustEmail treats every e-mail from the provider as verified, for
+	// corporate IdPs (Azure AD) that do not send the email_verified claim.
+	TrustEmail bool
+}
+
+// Identity is the verified user info taken from an ID token.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// Provider is a configured IdP. Discovery runs on first use, so the
+// application starts even when an IdP is unreachable.
+type Provider struct {
+	Config ProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	op, err := oidc.NewProvider(ctx, p.Config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc %s: discovery: %w", p.Config.Name, err)
+	}
+	scopes := p.Config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.Config.ClientID,
+		ClientSecret: p.Config.ClientSecret,
+		RedirectURL:  p.Config.RedirectURL,
+		Endpoint:     op.Endpoint(),
+		Scopes:       scopes,
+	}
+	p.verifier = op.Verifier(&oidc.Config{ClientID: p.Config.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// AuthCodeURL is the IdP login page to redirect the user to. nonce is
+// echoed in the ID token and checked by Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	cfg, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state, oidc.Nonce(nonce)), nil
+}
+
+// Exchange trades the callback code for tokens and validates the ID token:
+// signature, issuer, audience, expiry and nonce.
+func (p *Provider) Exchange(ctx context.Context, code, nonce string) (Identity, error) {
+	cfg, verifier, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	token, err := cfg.Exchange(ctx, code)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidc %s: token exchange: %w", p.Config.Name, err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, fmt.Errorf("oidc %s: no id_token in response", p.Config.Name)
+	}
+	idToken, err := verifier.Verify(ctx, raw)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidc %s: %w", p.Config.Name, err)
+	}
+	if nonce == "" || idToken.Nonce != nonce {
+		return Identity{}, fmt.Errorf("oidc %s: nonce mismatch", p.Config.Name)
+	}
+
+	var claims struct {
+		Email             string      `json:"email"`
+		EmailVerified     interface{} `json:"email_verified"` // bool, у некоторых IdP — строка
+		Name              string      `json:"name"`
+		PreferredUsername string      `json:"preferred_username"`
+		Picture           string      `json:"picture"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("oidc %s: claims: %w", p.Config.Name, err)
+	}
+	id := Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: p.Config.TrustEmail || claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}
+	if id.Email == "" && strings.Contains(claims.PreferredUsername, "@") {
+		// Azure AD отдаёт UPN в preferred_username
+		id.Email = claims.PreferredUsername
+	}
+	if id.Name == "" {
+		id.Name = claims.PreferredUsername
+	}
+	return id, nil
+}
+
+// Registry holds the configured providers in display order.
+type Registry struct {
+	providers map[string]*Provider
+	order     []string
+}
+
+func NewRegistry(configs ...ProviderConfig) *Registry {
+	r := &Registry{providers: make(map[string]*Provider)}
+	for _, c := range configs {
+		if _, dup := r.providers[c.Name]; dup {
+			continue
+		}
+		r.providers[c.Name] = &Provider{Config: c}
+		r.order = append(r.order, c.Name)
+	}
+	return r
+}
+
+// Get returns the provider by its URL name.
+func (r *Registry) Get(name string) (*Provider, bool) {
+	if r == nil {
+		return nil, false
+	}
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// List returns the provider configs for the login buttons.
+func (r *Registry) List() []ProviderConfig {
+	if r == nil {
+		return nil
+	}
+	list := make([]ProviderConfig, 0, len(r.order))
+	for _, name := range r.order {
+		list = append(list, r.providers[name].Config)
+	}
+	return list
+}
+
+// ErrIncompleteProvider is returned for a provider missing issuer or client
+// credentials in the environment.
+var ErrIncompleteProvider = errors.New("OIDC provider needs issuer, client id and client secret")
+
+// ProvidersFromEnv reads Google (GOOGLE_*) and the providers listed in
+// OIDC_PROVIDERS=keycloak,azure, each configured with
+//
+//	OIDC_KEYCLOAK_ISSUER, OIDC_KEYCLOAK_CLIENT_ID, OIDC_KEYCLOAK_CLIENT_SECRET,
+//	OIDC_KEYCLOAK_NAME (button text), OIDC_KEYCLOAK_SCOPES (space separated),
+//	OIDC_KEYCLOAK_TRUST_EMAIL=true, OIDC_KEYCLOAK_REDIRECT_URL
+//
+// The redirect URL defaults to {baseURL}/auth/{name}/callback. Incomplete
+// providers are reported in errs and skipped.
+func ProvidersFromEnv(baseURL string) (configs []ProviderConfig, errs []error) {
+	if id, secret := os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET"); id != "" && secret != "" {
+		google := GoogleConfig(id, secret, os.Getenv("GOOGLE_REDIRECT_URL"))
+		if google.RedirectURL == "" {
+			google.RedirectURL = baseURL + "/auth/google/callback"
+		}
+		configs = append(configs, google)
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		env := func(key string) string {
+			return os.Getenv("OIDC_" + strings.ToUpper(name) + "_" + key)
+		}
+		c := ProviderConfig{
+			Name:         name,
+			DisplayName:  env("NAME"),
+			Issuer:       env("ISSUER"),
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			RedirectURL:  env("REDIRECT_URL"),
+			Scopes:       strings.Fields(env("SCOPES")),
+			TrustEmail:   env("TRUST_EMAIL") == "true",
+		}
+		if c.Issuer == "" || c.ClientID == "" || c.ClientSecret == "" {
+			errs = append(errs, fmt.Errorf("%s: %w", name, ErrIncompleteProvider))
+			continue
+		}
+		if c.DisplayName == "" {
+			c.DisplayName = name
+		}
+		if c.RedirectURL == "" {
+			c.RedirectURL = baseURL + "/auth/" + name + "/callback"
+		}
+		configs = append(configs, c)
+	}
+	return configs, errs
+}
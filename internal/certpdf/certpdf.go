@@ -0,0 +1,308 @@
+// Package certpdf renders course completion certificates as PDF documents
+// using the per-course CertificateTemplate settings.
+package certpdf
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/pdf"
+	"github.com/s/onlineCourse/internal/qrcode"
+)
+
+//go:embed fonts/*.ttf
+var fontFiles embed.FS
+
+// Layouts lists the supported template layouts.
+var Layouts = []string{"classic", "modern", "minimal"}
+
+// Data is what gets printed on a certificate.
+type Data struct {
+	Learner  string
+	Course   string
+	Author   string
+	IssuedAt time.Time
+	Grade    *int
+	Code     string
+	URL      string // страница проверки, кодируется в QR
+	Lang     string
+}
+
+// A4 альбомная
+const (
+	pageW = pdf.A4Height
+	pageH = pdf.A4Width
+)
+
+var (
+	colorText  = pdf.Color{R: 15, G: 23, B: 42}
+	colorMuted = pdf.Color{R: 100, G: 116, B: 139}
+	colorWhite = pdf.Color{R: 255, G: 255, B: 255}
+)
+
+type fonts struct {
+	regular, bold *pdf.Font
+}
+
+// Разбор TTF дорогой, поэтому шрифты читаются из embed.FS один раз;
+// разобранные файлы только читаются и годятся для любого числа документов.
+var parsedFonts struct {
+	once  sync.Once
+	files map[string]*pdf.FontFile
+	err   error
+}
+
+func parseFonts() (map[string]*pdf.FontFile, error) {
+	parsedFonts.once.Do(func() {
+		files := make(map[string]*pdf.FontFile)
+		for _, name := range []string{"DejaVuSans", "DejaVuSans-Bold", "DejaVuSerif", "DejaVuSerif-Bold"} {
+			data, err := fontFiles.ReadFile("fonts/" + name + ".ttf")
+			if err == nil {
+				files[name], err = pdf.ParseFont(data)
+			}
+			if err != nil {
+				parsedFonts.err = fmt.Errorf("certpdf: font %s: %w", name, err)
+				return
+			}
+		}
+		parsedFonts.files = files
+	})
+	return parsedFonts.files, parsedFonts.err
+}
+
+func loadFonts(doc *pdf.Document, serif bool) (fonts, error) {
+	regular, bold := "DejaVuSans", "DejaVuSans-Bold"
+	if serif {
+		regular, bold = "DejaVuSerif", "DejaVuSerif-Bold"
+	}
+	files, err := parseFonts()
+	if err != nil {
+		return fonts{}, err
+	}
+	return fonts{
+		regular: doc.UseFont(regular, files[regular]),
+		bold:    doc.UseFont(bold, files[bold]),
+	}, nil
+}
+
+// Render builds the certificate PDF.
+func Render(d Data, tpl models.CertificateTemplate) ([]byte, error) {
+	accent, ok := pdf.HexColor(tpl.AccentColor)
+	if !ok {
+		accent, _ = pdf.HexColor(models.DefaultCertificateTemplate(0).AccentColor)
+	}
+	heading := strings.TrimSpace(tpl.Heading)
+	if heading == "" {
+		heading = i18n.T(d.Lang, "cert.pdf_heading")
+	}
+	body := strings.TrimSpace(tpl.Body)
+	if body == "" {
+		body = i18n.T(d.Lang, "cert.pdf_body")
+	}
+
+	doc := pdf.New(pageW, pageH)
+	doc.SetTitle(heading + " — " + d.Learner)
+	f, err := loadFonts(doc, tpl.Layout == "classic" || tpl.Layout == "")
+	if err != nil {
+		return nil, err
+	}
+	p := doc.AddPage()
+
+	switch tpl.Layout {
+	case "modern":
+		renderModern(p, f, d, tpl, accent, heading, body)
+	case "minimal":
+		renderMinimal(p, f, d, tpl, accent, heading, body)
+	default:
+		renderClassic(p, f, d, tpl, accent, heading, body)
+	}
+
+	if err := drawQR(p, d.URL, pageW-150, pageH-170, 100); err != nil {
+		return nil, err
+	}
+	p.SetFillColor(colorMuted)
+	p.TextCenter(f.regular, 7, pageW-100, pageH-58, i18n.T(d.Lang, "cert.pdf_verify"))
+	p.TextCenter(f.regular, 6, pageW-100, pageH-48, d.Code)
+
+	return doc.Bytes(), nil
+}
+
+// Классика: двойная рамка, засечки, всё по центру
+func renderClassic(p *pdf.Page, f fonts, d Data, tpl models.CertificateTemplate, accent pdf.Color, heading, body string) {
+	p.SetStrokeColor(accent)
+	p.SetLineWidth(3)
+	p.StrokeRect(24, 24, pageW-48, pageH-48)
+	p.SetLineWidth(0.8)
+	p.StrokeRect(34, 34, pageW-68, pageH-68)
+
+	cx := pageW / 2
+	maxW := pageW - 200
+
+	p.SetFillColor(accent)
+	p.TextCenter(f.bold, 12, cx, 90, "CoursePlatform")
+	size := f.bold.FitSize(strings.ToUpper(heading), 26, maxW)
+	p.TextCenter(f.bold, size, cx, 140, strings.ToUpper(heading))
+
+	p.SetFillColor(colorMuted)
+	p.TextCenter(f.regular, 12, cx, 190, i18n.T(d.Lang, "cert.holder"))
+	p.SetFillColor(colorText)
+	p.TextCenter(f.bold, f.bold.FitSize(d.Learner, 34, maxW), cx, 235, d.Learner)
+	p.SetStrokeColor(accent)
+	p.SetLineWidth(0.8)
+	p.Line(cx-160, 250, cx+160, 250)
+
+	p.SetFillColor(colorMuted)
+	p.TextCenter(f.regular, 13, cx, 280, body)
+	p.SetFillColor(accent)
+	y := 315.0
+	for _, line := range wrap(f.bold, 22, "«"+d.Course+"»", maxW, 2) {
+		p.TextCenter(f.bold, 22, cx, y, line)
+		y += 28
+	}
+
+	drawFooter(p, f, d, tpl, 110, pageH-110, colorText)
+}
+
+// Современный: цветная полоса слева, текст по левому краю
+func renderModern(p *pdf.Page, f fonts, d Data, tpl models.CertificateTemplate, accent pdf.Color, heading, body string) {
+	p.SetFillColor(accent)
+	p.FillRect(0, 0, 190, pageH)
+	p.SetFillColor(colorWhite)
+	p.Text(f.bold, 16, 30, 70, "CoursePlatform")
+	p.Text(f.regular, 10, 30, pageH-60, d.IssuedAt.Format("02.01.2006"))
+
+	x := 230.0
+	maxW := pageW - x - 60
+
+	p.SetFillColor(accent)
+	for i, line := range wrap(f.bold, 24, heading, maxW, 2) {
+		p.Text(f.bold, 24, x, 110+float64(i)*30, line)
+	}
+
+	p.SetFillColor(colorMuted)
+	p.Text(f.regular, 12, x, 200, i18n.T(d.Lang, "cert.holder"))
+	p.SetFillColor(colorText)
+	p.Text(f.bold, f.bold.FitSize(d.Learner, 36, maxW), x, 245, d.Learner)
+
+	p.SetFillColor(colorMuted)
+	p.Text(f.regular, 13, x, 290, body)
+	p.SetFillColor(colorText)
+	y := 325.0
+	for _, line := range wrap(f.bold, 22, d.Course, maxW, 2) {
+		p.Text(f.bold, 22, x, y, line)
+		y += 28
+	}
+
+	drawFooter(p, f, d, tpl, x, pageH-110, colorText)
+}
+
+// Минимализм: тонкая линия акцентного цвета и много воздуха
+func renderMinimal(p *pdf.Page, f fonts, d Data, tpl models.CertificateTemplate, accent pdf.Color, heading, body string) {
+	p.SetFillColor(accent)
+	p.FillRect(0, 0, pageW, 8)
+
+	cx := pageW / 2
+	maxW := pageW - 200
+
+	p.SetFillColor(colorMuted)
+	p.TextCenter(f.regular, 14, cx, 120, heading)
+	p.SetFillColor(colorText)
+	p.TextCenter(f.bold, f.bold.FitSize(d.Learner, 38, maxW), cx, 210, d.Learner)
+	p.SetFillColor(colorMuted)
+	p.TextCenter(f.regular, 13, cx, 260, body)
+	p.SetFillColor(accent)
+	y := 295.0
+	for _, line := range wrap(f.bold, 20, d.Course, maxW, 2) {
+		p.TextCenter(f.bold, 20, cx, y, line)
+		y += 26
+	}
+
+	drawFooter(p, f, d, tpl, 110, pageH-110, colorText)
+}
+
+// drawFooter prints the issue date, grade and signer in columns starting at x.
+func drawFooter(p *pdf.Page, f fonts, d Data, tpl models.CertificateTemplate, x, y float64, color pdf.Color) {
+	type column struct{ label, value string }
+	cols := []column{{i18n.T(d.Lang, "cert.issued"), d.IssuedAt.Format("02.01.2006")}}
+	if tpl.ShowGrade && d.Grade != nil {
+		cols = append(cols, column{i18n.T(d.Lang, "cert.grade"), fmt.Sprintf("%d%%", *d.Grade)})
+	}
+	if tpl.SignerName != "" {
+		label := tpl.SignerTitle
+		if label == "" {
+			label = i18n.T(d.Lang, "cert.pdf_author")
+		}
+		cols = append(cols, column{label, tpl.SignerName})
+	} else if d.Author != "" {
+		cols = append(cols, column{i18n.T(d.Lang, "cert.pdf_author"), d.Author})
+	}
+
+	const colW = 150.0
+	for i, c := range cols {
+		cx := x + float64(i)*colW
+		p.SetFillColor(colorMuted)
+		p.Text(f.regular, 9, cx, y, strings.ToUpper(c.label))
+		p.SetFillColor(color)
+		p.Text(f.bold, f.bold.FitSize(c.value, 13, colW-16), cx, y+20, c.value)
+	}
+}
+
+// drawQR draws the verification QR code with its quiet zone at x, y.
+func drawQR(p *pdf.Page, url string, x, y, size float64) error {
+	code, err := qrcode.Encode([]byte(url))
+	if err != nil {
+		return err
+	}
+	module := size / float64(code.Size+8)
+	p.SetFillColor(colorWhite)
+	p.FillRect(x, y, size, size)
+	p.SetFillColor(colorText)
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; col++ {
+			if !code.Modules[row][col] {
+				continue
+			}
+			// Соседние тёмные модули в строке рисуются одним прямоугольником
+			start := col
+			for col+1 < code.Size && code.Modules[row][col+1] {
+				col++
+			}
+			p.FillRect(x+float64(start+4)*module, y+float64(row+4)*module, float64(col-start+1)*module, module)
+		}
+	}
+	return nil
+}
+
+// wrap splits text into at most maxLines lines fitting maxWidth; a last line
+// that still overflows is cut with an ellipsis.
+func wrap(f *pdf.Font, size float64, text string, maxWidth float64, maxLines int) []string {
+	words := strings.Fields(text)
+	var lines []string
+	cur := ""
+	for _, w := range words {
+		next := strings.TrimSpace(cur + " " + w)
+		if cur != "" && f.Width(next, size) > maxWidth && len(lines) < maxLines-1 {
+			lines = append(lines, cur)
+			cur = w
+			continue
+		}
+		cur = next
+	}
+	if cur != "" {
+		lines = append(lines, cur)
+	}
+	if n := len(lines); n > 0 && f.Width(lines[n-1], size) > maxWidth {
+		// Последняя строка не влезает — обрезаем с многоточием
+		last := []rune(lines[n-1])
+		for len(last) > 1 && f.Width(string(last)+"…", size) > maxWidth {
+			last = last[:len(last)-1]
+		}
+		lines[n-1] = string(last) + "…"
+	}
+	return lines
+}
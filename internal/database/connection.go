@@ -10,7 +10,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func Connect() (*gorm.DB, error) {
+// DSN returns the connection string of the database.
+func DSN() string {
 	// Берем строку подключения из .env через переменную окружения
 	dsn := os.Getenv("DATABASE_URL")
 
@@ -19,6 +20,11 @@ func Connect() (*gorm.DB, error) {
 	if dsn == "" {
 		dsn = "host=db user=postgres password=1234 dbname=testdb port=5432 sslmode=disable"
 	}
+	return dsn
+}
+
+func Connect() (*gorm.DB, error) {
+	dsn := DSN()
 
 	var db *gorm.DB
 	var err error
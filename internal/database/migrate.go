@@ -8,19 +8,47 @@ import (
 func AutoMigrate(db *gorm.DB) error {
 	if err := db.AutoMigrate(
 		&models.User{},
+		&models.AuthToken{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.Session{},
+		&models.APIToken{},
 		&models.Role{},
 		&models.Course{},
 		&models.Module{},
 		&models.Lesson{},
 		&models.ContentBlock{},
 		&models.Enrollment{},
+		&models.EnrollmentHistory{},
 		&models.LessonProgress{},
 		&models.QuizAttempt{},
 		&models.Comment{},
+		&models.CommentMention{},
+		&models.Notification{},
+		&models.EmailPreference{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.Job{},
+		&models.Question{},
+		&models.Answer{},
+		&models.QAVote{},
+		&models.CourseAssistant{},
 		&models.Review{},
+		&models.ReviewVote{},
+		&models.Report{},
+		&models.BannedWord{},
+		&models.UserRestriction{},
 		&models.Certificate{},
+		&models.CertificateEvent{},
+		&models.CertificateTemplate{},
+		&models.SigningKey{},
 		&models.UserLog{},
 		&models.Reaction{},
+		&models.CourseInvite{},
+		&models.InviteRedemption{},
+		&models.PendingInvitation{},
+		&models.ApplicationField{},
+		&models.EnrollmentAnswer{},
 	); err != nil {
 		return err
 	}
@@ -32,5 +60,11 @@ func AutoMigrate(db *gorm.DB) error {
 	`).Error; err != nil {
 		return err
 	}
-	return db.Exec(`ALTER TABLE users ALTER COLUMN public_id SET NOT NULL`).Error
+	if err := db.Exec(`ALTER TABLE users ALTER COLUMN public_id SET NOT NULL`).Error; err != nil {
+		return err
+	}
+
+	// Google подтверждает адрес сам: пользователи, пришедшие до локального
+	// входа, считаются подтверждёнными.
+	return db.Exec(`UPDATE users SET email_verified = true WHERE google_id <> '' AND NOT email_verified`).Error
 }
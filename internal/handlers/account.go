@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/auth"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/mail"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+// Срок жизни ссылок из писем
+const (
+	verifyEmailTTL   = 48 * time.Hour
+	passwordResetTTL = time.Hour
+	magicLinkTTL     = 15 * time.Minute
+)
+
+// AuthPageData is the state of the login / registration pages.
+type AuthPageData struct {
+	Mode      string // login, register, forgot, reset, magic, verify, 2fa
+	Token     string // из ссылки в письме (reset, magic, verify)
+	Providers []auth.ProviderConfig
+}
+
+var authPageModes = map[string]string{
+	"/login":           "login",
+	"/register":        "register",
+	"/forgot-password": "forgot",
+	"/reset-password":  "reset",
+	"/auth/magic":      "magic",
+	"/auth/verify":     "verify",
+	"/login/2fa":       "2fa",
+}
+
+var authPageTitles = map[string]string{
+	"login":    "auth.login_title",
+	"register": "auth.register_title",
+	"forgot":   "auth.forgot_title",
+	"reset":    "auth.reset_title",
+	"magic":    "auth.magic_title",
+	"verify":   "auth.verify_title",
+	"2fa":      "auth.2fa_title",
+}
+
+// GET /login, /register, /forgot-password, /reset-password?token=, /auth/magic?token=,
+// /auth/verify?token=
+func (h *Handler) HandleAuthPage(w http.ResponseWriter, r *http.Request) {
+	mode := authPageModes[r.URL.Path]
+	_, loggedIn := h.GetAuthenticatedUserID(r)
+	if loggedIn && (mode == "login" || mode == "register") {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if mode == "2fa" {
+		// Форма кода — только посреди входа с 2FA или для подтверждения вошедшего
+		session, _ := h.Store.Get(r, "session")
+		if _, pending := session.Values["mfa_user_id"].(uint); !pending && !loggedIn {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+	}
+
+	lang := h.DetectLang(r)
+	data := PageData{
+		Title:       i18n.T(lang, authPageTitles[mode]),
+		CurrentPath: r.URL.Path,
+		Lang:        lang,
+		TransJSON:   BuildTransJSON(lang),
+		Auth: AuthPageData{
+			Mode:      mode,
+			Token:     r.URL.Query().Get("token"),
+			Providers: h.Providers.List(),
+		},
+	}
+	h.Tmpl.ExecuteTemplate(w, "authPage", data)
+}
+
+// sendAuthLetter e-mails a one-time link to path?token=… .
+func (h *Handler) sendAuthLetter(r *http.Request, purpose, email, name, path string, ttl time.Duration) error {
+	token, err := storage.CreateAuthToken(h.DB, purpose, email, ttl)
+	if err != nil {
+		return err
+	}
+	msg, err := mail.Render(mail.Letter{
+		Kind:      purpose,
+		Lang:      h.DetectLang(r),
+		Vars:      map[string]string{"name": name},
+		ActionURL: siteBaseURL() + path + "?token=" + token,
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = email
+	return h.Mailer.Send(msg)
+}
+
+// loggedIn answers a successful API login with the page to go to: the
+// saved one, or the code form when two-factor authentication is on.
+func (h *Handler) loggedIn(w http.ResponseWriter, r *http.Request, userID uint, details string) {
+	redirect := h.startLogin(w, r, userID, details)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"redirect": redirect})
+}
+
+type authRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+func decodeAuthRequest(w http.ResponseWriter, r *http.Request) (authRequest, bool) {
+	var req authRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// POST /api/auth/register  {"email", "name", "password"}
+func (h *Handler) RegisterAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	user, err := storage.RegisterLocalUser(h.DB, req.Email, req.Name, req.Password)
+	switch {
+	case errors.Is(err, storage.ErrBadEmail), errors.Is(err, storage.ErrWeakPassword):
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrEmailTaken):
+		studioJSONError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.sendAuthLetter(r, models.TokenVerifyEmail, user.Email, user.Name, "/auth/verify", verifyEmailTTL); err != nil {
+		log.Printf("RegisterAPI: verification letter to %s: %v", user.Email, err)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// POST /api/auth/login  {"email", "password"}
+func (h *Handler) LoginAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	user, err := storage.AuthenticateLocal(h.DB, req.Email, req.Password)
+	switch {
+	case errors.Is(err, storage.ErrEmailNotVerified):
+		studioJSONError(w, "email_not_verified", http.StatusForbidden)
+		return
+	case err != nil:
+		studioJSONError(w, storage.ErrBadCredentials.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.loggedIn(w, r, user.ID, "Вход по паролю")
+}
+
+// POST /api/auth/verify/resend  {"email"}
+//
+// Как и остальные запросы писем, отвечает 204 независимо от того, есть ли
+// такой адрес: по ответу нельзя узнать, кто зарегистрирован.
+func (h *Handler) ResendVerificationAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	if user, err := storage.FindUserByEmail(h.DB, req.Email); err == nil && !user.EmailVerified {
+		if err := h.sendAuthLetter(r, models.TokenVerifyEmail, user.Email, user.Name, "/auth/verify", verifyEmailTTL); err != nil {
+			log.Printf("ResendVerificationAPI: %v", err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/auth/verify  {"token"} — подтверждает адрес и сразу входит.
+// Ссылка из письма ведёт на страницу /auth/verify с кнопкой: почтовые
+// сканеры открывают ссылки сами, и GET не должен гасить токен.
+func (h *Handler) VerifyEmailAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	email, err := storage.ConsumeAuthToken(h.DB, models.TokenVerifyEmail, req.Token)
+	if err != nil {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := storage.VerifyEmail(h.DB, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		studioJSONError(w, storage.ErrInvalidAuthToken.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.loggedIn(w, r, user.ID, "Подтверждение адреса")
+}
+
+// POST /api/auth/password/forgot  {"email"}
+func (h *Handler) ForgotPasswordAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	if user, err := storage.FindUserByEmail(h.DB, req.Email); err == nil {
+		if err := h.sendAuthLetter(r, models.TokenPasswordReset, user.Email, user.Name, "/reset-password", passwordResetTTL); err != nil {
+			log.Printf("ForgotPasswordAPI: %v", err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/auth/password/reset  {"token", "password"}
+func (h *Handler) ResetPasswordAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	// Проверяем до погашения токена, чтобы ссылка осталась рабочей
+	if err := storage.CheckPassword(req.Password); err != nil {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	email, err := storage.ConsumeAuthToken(h.DB, models.TokenPasswordReset, req.Token)
+	if err != nil {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := storage.ResetPassword(h.DB, email, req.Password)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		studioJSONError(w, storage.ErrInvalidAuthToken.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	// Кто знал старый пароль, больше не в аккаунте
+	if err := storage.RevokeUserSessions(h.DB, user.ID); err != nil {
+		log.Printf("ResetPasswordAPI: revoke sessions: %v", err)
+	}
+	h.loggedIn(w, r, user.ID, "Вход после сброса пароля")
+}
+
+// POST /api/auth/magic-link  {"email"} — письмо со ссылкой для входа без
+// пароля; для нового адреса аккаунт создаётся при переходе по ссылке.
+func (h *Handler) MagicLinkAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	email := storage.NormalizeEmail(req.Email)
+	if !storage.ValidEmail(email) {
+		studioJSONError(w, storage.ErrBadEmail.Error(), http.StatusBadRequest)
+		return
+	}
+	name := email
+	if user, err := storage.FindUserByEmail(h.DB, email); err == nil {
+		email, name = user.Email, user.Name
+	}
+	if err := h.sendAuthLetter(r, models.TokenMagicLink, email, name, "/auth/magic", magicLinkTTL); err != nil {
+		log.Printf("MagicLinkAPI: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/auth/magic  {"token"}
+//
+// Страница /auth/magic только показывает кнопку: почтовые сканеры
+// открывают ссылки из писем и не должны гасить токен.
+func (h *Handler) MagicLoginAPI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAuthRequest(w, r)
+	if !ok {
+		return
+	}
+	email, err := storage.ConsumeAuthToken(h.DB, models.TokenMagicLink, req.Token)
+	if err != nil {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := storage.MagicLinkUser(h.DB, email)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.loggedIn(w, r, user.ID, "Вход по ссылке из письма")
+}
+
+// GET /api/account/identities — внешние провайдеры и привязан ли каждый к аккаунту
+func (h *Handler) ListIdentitiesAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var linked []string
+	h.DB.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Pluck("provider", &linked)
+	var user models.User
+	h.DB.Select("google_id").First(&user, userID)
+	if user.GoogleID != "" {
+		linked = append(linked, auth.GoogleName)
+	}
+
+	type identityView struct {
+		Provider    string `json:"provider"`
+		DisplayName string `json:"display_name"`
+		Linked      bool   `json:"linked"`
+	}
+	list := []identityView{}
+	for _, p := range h.Providers.List() {
+		v := identityView{Provider: p.Name, DisplayName: p.DisplayName}
+		for _, name := range linked {
+			v.Linked = v.Linked || name == p.Name
+		}
+		list = append(list, v)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GET /api/account/sessions — устройства, на которых открыт аккаунт
+func (h *Handler) ListSessionsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.GetAuthenticatedUserID(r)
+	session, _ := h.Store.Get(r, "session")
+	list, err := storage.UserSessions(h.DB, userID, session.ID)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// DELETE /api/account/sessions/{id} — выйти на одном устройстве
+func (h *Handler) RevokeSessionAPI(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.GetAuthenticatedUserID(r)
+	sessionID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := storage.RevokeSession(h.DB, userID, uint(sessionID))
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		studioJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.logAction(userID, models.LogSessionRevoked, fmt.Sprintf("Сессия #%d", sessionID), 0, 0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/account/sessions/logout-all — выйти на всех устройствах, включая это
+func (h *Handler) LogoutEverywhereAPI(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.GetAuthenticatedUserID(r)
+	if err := storage.RevokeUserSessions(h.DB, userID); err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.logAction(userID, models.LogSessionRevoked, "Все устройства", 0, 0)
+
+	session, _ := h.Store.Get(r, "session")
+	session.Options.MaxAge = -1
+	session.Save(r, w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"redirect": "/"})
+}
@@ -9,7 +9,10 @@ import (
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/handlers"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/realtime"
+	"github.com/s/onlineCourse/internal/storage"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -67,20 +70,9 @@ func (s *Service) HandleCourseByIDAPI(w http.ResponseWriter, r *http.Request) {
 func (s *Service) getCourses(w http.ResponseWriter, r *http.Request) {
 	var courses []models.Course
 
-	session, err := s.Store.Get(r, "session")
-	if err != nil {
-		response := map[string]string{
-			"error": "Вы не авторизованы",
-			"code":  "401",
-		}
-		json.NewEncoder(w).Encode(response)
-		return
-	}
-
-	userIDvalue := session.Values["user_id"]
-	userID, ok := userIDvalue.(uint)
-	if !ok || userID == 0 {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	userID, ok := s.GetAuthenticatedUserID(r)
+	if !ok {
+		jsonError(w, "Вы не авторизованы", http.StatusUnauthorized)
 		return
 	}
 	result := s.DB.Where("author_id = ?", userID).Preload("Author").Order("created_at desc").Find(&courses)
@@ -112,31 +104,12 @@ func (s *Service) createCourse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := s.Store.Get(r, "session")
-	if err != nil {
-		log.Println("Ошибка получения сессии:", err)
-		jsonError(w, "Session error", http.StatusInternalServerError)
-		return
-	}
-
-	val := session.Values["user_id"]
-	if val == nil {
+	userID, ok := s.GetAuthenticatedUserID(r)
+	if !ok {
 		jsonError(w, "User not identified (Empty Session)", http.StatusUnauthorized)
 		return
 	}
 
-	var userID uint
-	if v, ok := val.(uint); ok {
-		userID = v
-	} else if v, ok := val.(int); ok {
-		userID = uint(v)
-	} else if v, ok := val.(float64); ok {
-		userID = uint(v)
-	} else {
-		jsonError(w, "User ID type mismatch", http.StatusUnauthorized)
-		return
-	}
-
 	course := models.Course{
 		Title:       input.Title,
 		Description: input.Description,
@@ -540,30 +513,11 @@ func (s *Service) GetCourseStructure(w http.ResponseWriter, r *http.Request) {
 		RequestStatus: "",
 	}
 
-	session, _ := s.Store.Get(r, "session")
-	val := session.Values["user_id"]
-
-	if val != nil {
-		var userID uint
-		var isValidUser bool = false
-
-		if v, ok := val.(uint); ok {
-			userID = v
-			isValidUser = true
-		} else if v, ok := val.(int); ok {
-			userID = uint(v)
-			isValidUser = true
-		} else if v, ok := val.(float64); ok {
-			userID = uint(v)
-			isValidUser = true
-		}
-
-		if isValidUser {
-			resp.IsAuth = true
-			var enrollment models.Enrollment
-			if err := s.DB.Where("user_id = ? AND course_id = ?", userID, course.ID).First(&enrollment).Error; err == nil {
-				resp.RequestStatus = enrollment.Status
-			}
+	if userID, ok := s.GetAuthenticatedUserID(r); ok {
+		resp.IsAuth = true
+		var enrollment models.Enrollment
+		if err := s.DB.Where("user_id = ? AND course_id = ?", userID, course.ID).First(&enrollment).Error; err == nil {
+			resp.RequestStatus = enrollment.Status
 		}
 	}
 
@@ -572,62 +526,102 @@ func (s *Service) GetCourseStructure(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Service) SubmitEnrollment(w http.ResponseWriter, r *http.Request) {
-	session, err := s.Store.Get(r, "session")
-	if err != nil {
-		jsonError(w, "Session error", http.StatusInternalServerError)
+	userID, ok := s.GetAuthenticatedUserID(r)
+	if !ok {
+		jsonError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	val := session.Values["user_id"]
-	if val == nil {
-		jsonError(w, "Unauthorized", http.StatusUnauthorized)
+	var req struct {
+		CourseID uint                             `json:"course_id"`
+		Answers  []storage.ApplicationAnswerInput `json:"answers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	var userID uint
-	if v, ok := val.(uint); ok {
-		userID = v
-	} else if v, ok := val.(int); ok {
-		userID = uint(v)
-	} else if v, ok := val.(float64); ok {
-		userID = uint(v)
-	} else {
-		jsonError(w, "User ID type mismatch", http.StatusUnauthorized)
+	// Анкета курса проверяется до создания заявки
+	answers, err := storage.ValidateApplication(storage.ApplicationFields(s.DB, req.CourseID), userID, req.Answers)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var req struct {
-		CourseID uint `json:"course_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonError(w, "Invalid JSON", http.StatusBadRequest)
+	var user models.User
+	if err := s.DB.Select("id, email").First(&user, userID).Error; err != nil {
+		jsonError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	var existing models.Enrollment
 	if err := s.DB.Where("user_id = ? AND course_id = ?", userID, req.CourseID).First(&existing).Error; err == nil {
-		jsonError(w, "Заявка уже существует", http.StatusConflict)
+		// После отказа, ухода или истечения срока заявку можно подать снова
+		if !handlers.CanRerequest(existing.Status) {
+			jsonError(w, "Заявка уже существует", http.StatusConflict)
+			return
+		}
+		if err := storage.RerequestEnrollment(s.DB, &existing, user, ""); err != nil {
+			jsonError(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if err := storage.SaveApplicationAnswers(s.DB, existing.ID, answers); err != nil {
+			jsonError(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		s.publishEnrollment(existing)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":         "success",
+			"request_status": existing.Status,
+		})
 		return
 	}
 
-	enrollment := models.Enrollment{
-		UserID:   userID,
-		CourseID: req.CourseID,
-		Status:   "pending",
+	var course models.Course
+	if err := s.DB.First(&course, req.CourseID).Error; err != nil {
+		jsonError(w, "Курс не найден", http.StatusNotFound)
+		return
 	}
 
-	if err := s.DB.Create(&enrollment).Error; err != nil {
+	// Правила курса решают: сразу одобрить, поставить в лист ожидания или ждать проверки
+	enrollment, err := storage.CreateEnrollment(s.DB, user, course)
+	if err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err := storage.SaveApplicationAnswers(s.DB, enrollment.ID, answers); err != nil {
 		jsonError(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	s.publishEnrollment(enrollment)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"status":         "success",
-		"request_status": "pending",
+		"request_status": enrollment.Status,
 	})
 }
 
+// publishEnrollment tells admins and the course author about a new or
+// renewed enrollment request so their lists refresh live.
+func (s *Service) publishEnrollment(e models.Enrollment) {
+	var course models.Course
+	if err := s.DB.Select("id, title, author_id").First(&course, e.CourseID).Error; err != nil {
+		return
+	}
+	channels := []string{realtime.AdminsChannel}
+	if course.AuthorID != 0 {
+		channels = append(channels, realtime.UserChannel(course.AuthorID))
+	}
+	s.Events.Publish(realtime.Event{Type: "enrollment.submitted", Data: map[string]interface{}{
+		"enrollment_id": e.ID,
+		"course_id":     course.ID,
+		"course_title":  course.Title,
+		"status":        e.Status,
+	}}, channels...)
+}
+
 // Compact JSON helper
 func compactJSON(data []byte) string {
 	dst := &bytes.Buffer{}
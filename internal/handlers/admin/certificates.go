@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// API: Сертификаты (отзыв и перевыпуск)
+// ==========================================
+
+// GET /api/admin/certificates?search=&course_id=&status=active|revoked&page=&limit=
+func (s *Service) GetCertificatesAPI(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	db := s.DB.Model(&models.Certificate{}).
+		Joins("JOIN users ON users.id = certificates.user_id")
+
+	if courseID := query.Get("course_id"); courseID != "" && courseID != "0" {
+		db = db.Where("certificates.course_id = ?", courseID)
+	}
+	switch query.Get("status") {
+	case "active":
+		db = db.Where("certificates.revoked_at IS NULL")
+	case "revoked":
+		db = db.Where("certificates.revoked_at IS NOT NULL")
+	}
+	if search := query.Get("search"); search != "" {
+		like := "%" + search + "%"
+		db = db.Where("users.name ILIKE ? OR users.email ILIKE ? OR certificates.code ILIKE ?", like, like, like)
+	}
+
+	var total int64
+	db.Count(&total)
+
+	var certs []models.Certificate
+	err := db.Preload("User").Preload("Course").
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Preload("History.Actor").
+		Order("certificates.issued_at DESC").
+		Limit(limit).Offset((page - 1) * limit).
+		Find(&certs).Error
+	if err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  certs,
+		"total": total,
+		"page":  page,
+		"pages": int(math.Ceil(float64(total) / float64(limit))),
+	})
+}
+
+// POST /api/admin/certificates/{id}/revoke  {"reason": "..."}
+// POST /api/admin/certificates/{id}/reissue {"reason": "..."}
+func (s *Service) ChangeCertificateAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var cert models.Certificate
+	if err := s.DB.First(&cert, vars["id"]).Error; err != nil {
+		jsonError(w, "Сертификат не найден", http.StatusNotFound)
+		return
+	}
+	_, adminID := s.GetUserRoleID(r)
+	s.ChangeCertificate(w, r, cert, adminID, vars["action"])
+}
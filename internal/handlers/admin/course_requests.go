@@ -8,6 +8,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/s/onlineCourse/internal/handlers"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
 )
 
 // HandleCourseRequestsPage renders the admin course-approval page.
@@ -93,6 +94,23 @@ func (s *Service) ReviewCourseRequestAPI(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	_, adminID := s.GetUserRoleID(r)
+	kind := models.NotifyCourseApproved
+	if req.Action == "reject" {
+		kind = models.NotifyCourseRejected
+	}
+	storage.Notify(s.DB, models.Notification{
+		UserID:  course.AuthorID,
+		ActorID: adminID,
+		Kind:    kind,
+		Subject: course.Title,
+		Detail:  req.ReviewNote,
+		Link:    "/studio",
+	})
+	if req.Action == "approve" {
+		storage.DispatchCourseApproved(s.DB, course)
+	}
+
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"id":           id,
 		"admin_status": updates["admin_status"],
@@ -2,14 +2,18 @@ package admin
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/s/onlineCourse/internal/handlers"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
 )
 
 func toString(v interface{}) string {
@@ -69,17 +73,8 @@ func (s *Service) HandleEnrollmentsPage(w http.ResponseWriter, r *http.Request)
 // ==========================================
 func (s *Service) GetEnrollmentsAPI(w http.ResponseWriter, r *http.Request) {
 
-	session, err := s.Store.Get(r, "session")
-	if err != nil {
-		// Логируем ошибку и, возможно, перенаправляем на страницу входа или показываем ошибку сервера.
-		// В продакшене тут лучше логгировать 'err'.
-		http.Error(w, "Ошибка получения сессии. Попробуйте войти снова.", http.StatusInternalServerError)
-		return
-	}
-
-	userIDvalue := session.Values["user_id"]
-	userID, ok := userIDvalue.(uint)
-	if !ok || userID == 0 { // Добавим проверку, что userID не равен 0 для неаутентифицированных пользователей
+	userID, ok := s.GetAuthenticatedUserID(r)
+	if !ok {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
@@ -170,7 +165,7 @@ func (s *Service) GetEnrollmentsAPI(w http.ResponseWriter, r *http.Request) {
 	offset := (page - 1) * limit
 
 	// Сортируем: сначала новые
-	err = db.Order("enrollments.created_at DESC").
+	err := db.Order("enrollments.created_at DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&enrollments).Error
@@ -201,6 +196,7 @@ func (s *Service) UpdateEnrollmentStatusAPI(w http.ResponseWriter, r *http.Reque
 	// Читаем JSON body
 	var req struct {
 		Status string `json:"status"` // ожидаем "approved" или "rejected"
+		Reason string `json:"reason"` // попадёт в историю заявки
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonError(w, "Неверный формат JSON", http.StatusBadRequest)
@@ -208,14 +204,26 @@ func (s *Service) UpdateEnrollmentStatusAPI(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Валидация статуса (опционально, но полезно)
-	if req.Status != "approved" && req.Status != "rejected" && req.Status != "pending" {
+	switch req.Status {
+	case models.EnrollmentApproved, models.EnrollmentRejected, models.EnrollmentPending, models.EnrollmentWaitlisted:
+	default:
 		jsonError(w, "Недопустимый статус", http.StatusBadRequest)
 		return
 	}
 
-	// Обновляем в БД
-	// Используем Model(&models.Enrollment{}), чтобы GORM знал, какую таблицу обновлять
-	if err := s.DB.Model(&models.Enrollment{}).Where("id = ?", id).Update("status", req.Status).Error; err != nil {
+	var enrollment models.Enrollment
+	if err := s.DB.First(&enrollment, id).Error; err != nil {
+		jsonError(w, "Заявка не найдена", http.StatusNotFound)
+		return
+	}
+
+	// Обновляем в БД: проверка мест, срок доступа и продвижение листа ожидания
+	_, adminID := s.GetUserRoleID(r)
+	if err := storage.SetEnrollmentStatus(s.DB, &enrollment, req.Status, adminID, strings.TrimSpace(req.Reason)); err != nil {
+		if errors.Is(err, storage.ErrCourseFull) {
+			jsonError(w, "На курсе нет свободных мест", http.StatusConflict)
+			return
+		}
 		jsonError(w, "Ошибка при обновлении статуса", http.StatusInternalServerError)
 		return
 	}
@@ -223,3 +231,38 @@ func (s *Service) UpdateEnrollmentStatusAPI(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"result": "success"})
 }
+
+// ==========================================
+// API: Детали заявки с историей статусов
+// ==========================================
+func (s *Service) GetEnrollmentAPI(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var enrollment models.Enrollment
+	if err := s.DB.Preload("User").Preload("Course").Preload("Answers").
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Preload("History.Actor").
+		First(&enrollment, id).Error; err != nil {
+		jsonError(w, "Заявка не найдена", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// ==========================================
+// API: Массовая запись на курс из CSV
+// ==========================================
+func (s *Service) ImportEnrollmentsAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var course models.Course
+	if err := s.DB.First(&course, id).Error; err != nil {
+		jsonError(w, "Курс не найден", http.StatusNotFound)
+		return
+	}
+
+	_, userID := s.GetUserRoleID(r)
+	s.ImportEnrollmentsCSV(w, r, course, userID)
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/handlers"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// Фоновые задачи: очередь, ошибки, повтор
+// ==========================================
+
+func (s *Service) HandleJobsPage(w http.ResponseWriter, r *http.Request) {
+	roleID, userID := s.GetUserRoleID(r)
+	session, _ := s.Store.Get(r, "session")
+	lang := s.DetectLang(r)
+
+	name, _ := session.Values["name"].(string)
+	picture, _ := session.Values["picture"].(string)
+
+	data := handlers.PageData{
+		Title:           i18n.T(lang, "jobs.title"),
+		IsAuthenticated: userID != 0,
+		UserID:          userID,
+		RoleID:          roleID,
+		UserName:        name,
+		UserPictureURL:  picture,
+		CurrentPath:     r.URL.Path,
+		Lang:            lang,
+		TransJSON:       handlers.BuildTransJSON(lang),
+	}
+
+	s.Tmpl.ExecuteTemplate(w, "adminJobs", data)
+}
+
+// GET /api/admin/jobs?status=failed&kind=&page=
+func (s *Service) GetJobsAPI(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	const limit = 20
+
+	q := s.DB.Model(&models.Job{})
+	if status := query.Get("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if kind := query.Get("kind"); kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+
+	var total int64
+	var jobs []models.Job
+	if err := q.Count(&total).Error; err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err := q.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&jobs).Error; err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  jobs,
+		"total": total,
+		"page":  page,
+		"pages": int(math.Ceil(float64(total) / float64(limit))),
+		"stats": storage.JobStats(s.DB),
+	})
+}
+
+// POST /api/admin/jobs/{id}/retry
+func (s *Service) RetryJobAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	_, adminID := s.GetUserRoleID(r)
+
+	job, err := storage.RetryJob(s.DB, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		jsonError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrJobNotFailed) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	s.LogAction(adminID, models.LogJobRetry, fmt.Sprintf("%s #%d", job.Kind, job.ID), 0, 0)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/handlers"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+// ==========================================
+// Модерация: жалобы, запрещённые слова, муты
+// ==========================================
+
+func (s *Service) HandleModerationPage(w http.ResponseWriter, r *http.Request) {
+	roleID, userID := s.GetUserRoleID(r)
+	session, _ := s.Store.Get(r, "session")
+	lang := s.DetectLang(r)
+
+	name, _ := session.Values["name"].(string)
+	picture, _ := session.Values["picture"].(string)
+
+	data := handlers.PageData{
+		Title:           i18n.T(lang, "moderation.title"),
+		IsAuthenticated: userID != 0,
+		UserID:          userID,
+		RoleID:          roleID,
+		UserName:        name,
+		UserPictureURL:  picture,
+		CurrentPath:     r.URL.Path,
+		Lang:            lang,
+		TransJSON:       handlers.BuildTransJSON(lang),
+	}
+
+	s.Tmpl.ExecuteTemplate(w, "adminModeration", data)
+}
+
+// GET /api/admin/moderation?view=open|hidden&page=
+func (s *Service) GetModerationQueueAPI(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	const limit = 20
+
+	items, total, err := storage.ModerationQueue(s.DB, query.Get("view"), page, limit)
+	if err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  items,
+		"total": total,
+		"page":  page,
+		"pages": int(math.Ceil(float64(total) / float64(limit))),
+	})
+}
+
+// POST /api/admin/moderation/{type}/{id}/{action:hide|restore|delete}
+func (s *Service) ModerateAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	_, adminID := s.GetUserRoleID(r)
+
+	err := storage.ModerateContent(s.DB, vars["type"], uint(id), adminID, vars["action"])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		jsonError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	s.LogAction(adminID, models.LogModeration,
+		fmt.Sprintf("%s %s #%d", vars["action"], vars["type"], id), 0, 0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/admin/banned-words/{lang}
+func (s *Service) GetBannedWordsAPI(w http.ResponseWriter, r *http.Request) {
+	lang := mux.Vars(r)["lang"]
+	if !i18n.IsSupported(lang) {
+		jsonError(w, "Unsupported language", http.StatusBadRequest)
+		return
+	}
+
+	words := []string{}
+	s.DB.Model(&models.BannedWord{}).Where("lang = ?", lang).Order("word").Pluck("word", &words)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"lang": lang, "words": words})
+}
+
+// PUT /api/admin/banned-words/{lang}  {"words": ["...", "префикс*"]}
+func (s *Service) SetBannedWordsAPI(w http.ResponseWriter, r *http.Request) {
+	lang := mux.Vars(r)["lang"]
+	if !i18n.IsSupported(lang) {
+		jsonError(w, "Unsupported language", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Words []string `json:"words"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := storage.SetBannedWords(s.DB, lang, req.Words); err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	_, adminID := s.GetUserRoleID(r)
+	s.LogAction(adminID, models.LogModeration,
+		fmt.Sprintf("Список запрещённых слов (%s): %d", lang, len(req.Words)), 0, 0)
+	s.GetBannedWordsAPI(w, r)
+}
+
+// GET /api/admin/restrictions — действующие муты и баны
+func (s *Service) GetRestrictionsAPI(w http.ResponseWriter, r *http.Request) {
+	var list []models.UserRestriction
+	if err := s.DB.Preload("User").
+		Where("until IS NULL OR until > NOW()").
+		Order("created_at desc").Find(&list).Error; err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// PUT /api/admin/users/{id}/restriction  {"days": 7, "reason": "..."} — days 0 = бан
+func (s *Service) RestrictUserAPI(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	_, adminID := s.GetUserRoleID(r)
+
+	var req struct {
+		Days   int    `json:"days"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Days < 0 || req.Days > 365 {
+		jsonError(w, "days must be between 0 and 365", http.StatusBadRequest)
+		return
+	}
+	if uint(userID) == adminID {
+		jsonError(w, "Нельзя ограничить самого себя", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		jsonError(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+
+	rs, err := storage.RestrictUser(s.DB, user.ID, adminID, req.Days, req.Reason)
+	if err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	s.LogAction(adminID, models.LogModeration,
+		fmt.Sprintf("%s: %s (%d дн.) %s", rs.Kind, user.Email, req.Days, req.Reason), 0, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rs)
+}
+
+// DELETE /api/admin/users/{id}/restriction — снять мут или бан
+func (s *Service) LiftRestrictionAPI(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	_, adminID := s.GetUserRoleID(r)
+
+	if err := storage.LiftRestriction(s.DB, uint(userID)); err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	s.LogAction(adminID, models.LogModeration, fmt.Sprintf("Снято ограничение с пользователя #%d", userID), 0, 0)
+	w.WriteHeader(http.StatusNoContent)
+}
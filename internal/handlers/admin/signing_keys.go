@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// ==========================================
+// API: Ключи подписи сертификатов
+// ==========================================
+
+// GET /api/admin/signing-keys
+func (s *Service) GetSigningKeysAPI(w http.ResponseWriter, r *http.Request) {
+	keys, err := storage.PublicKeys(s.DB)
+	if err != nil {
+		jsonError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var counts []struct {
+		KeyID string
+		Count int64
+	}
+	s.DB.Model(&models.Certificate{}).Select("key_id, count(*) as count").
+		Where("key_id <> ''").Group("key_id").Scan(&counts)
+	signed := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		signed[c.KeyID] = c.Count
+	}
+
+	type keyRow struct {
+		storage.JWK
+		Certificates int64 `json:"certificates"`
+	}
+	rows := make([]keyRow, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, keyRow{JWK: k, Certificates: signed[k.Kid]})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": rows})
+}
+
+// POST /api/admin/signing-keys/rotate — новый активный ключ, прежний остаётся для проверки
+func (s *Service) RotateSigningKeyAPI(w http.ResponseWriter, r *http.Request) {
+	key, err := storage.RotateSigningKey(s.DB)
+	if err != nil {
+		jsonError(w, "Не удалось создать ключ", http.StatusInternalServerError)
+		return
+	}
+	_, adminID := s.GetUserRoleID(r)
+	s.LogAction(adminID, models.LogSigningKey, "Ротация: новый ключ "+key.KeyID, 0, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"kid": key.KeyID, "status": key.Status})
+}
+
+// POST /api/admin/signing-keys/{kid}/revoke — ключ скомпрометирован, его подписи недействительны
+func (s *Service) RevokeSigningKeyAPI(w http.ResponseWriter, r *http.Request) {
+	kid := mux.Vars(r)["kid"]
+	if err := storage.RevokeSigningKey(s.DB, kid); err != nil {
+		jsonError(w, "Ключ не найден", http.StatusNotFound)
+		return
+	}
+	_, adminID := s.GetUserRoleID(r)
+	s.LogAction(adminID, models.LogSigningKey, "Отозван ключ "+kid, 0, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": models.SigningKeyRevoked})
+}
@@ -10,13 +10,8 @@ import (
 )
 
 func (serv Service) GetUsersAPI(w http.ResponseWriter, r *http.Request) {
-	session, err := serv.Store.Get(r, "session")
-	if err != nil {
-		http.Error(w, `{"error":"session error"}`, http.StatusInternalServerError)
-		return
-	}
-	callerID, _ := session.Values["user_id"].(uint)
-	if callerID == 0 {
+	_, ok := serv.GetAuthenticatedUserID(r)
+	if !ok {
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
@@ -108,13 +103,8 @@ func (serv Service) GetUsersAPI(w http.ResponseWriter, r *http.Request) {
 }
 
 func (serv Service) UpdateUserRoleAPI(w http.ResponseWriter, r *http.Request) {
-	session, err := serv.Store.Get(r, "session")
-	if err != nil {
-		http.Error(w, `{"error":"session error"}`, http.StatusInternalServerError)
-		return
-	}
-	callerID, _ := session.Values["user_id"].(uint)
-	if callerID == 0 {
+	callerID, ok := serv.GetAuthenticatedUserID(r)
+	if !ok {
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+type apiTokenKey struct{}
+
+// WithAPIToken authenticates the "Authorization: Bearer" token of a request
+// and keeps it in the request context for GetAuthenticatedUserID. A request
+// without the header is returned as is.
+func (h *Handler) WithAPIToken(r *http.Request) (*http.Request, error) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return r, nil
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	token, err := storage.AuthenticateAPIToken(h.DB, strings.TrimSpace(raw), ip)
+	if err != nil {
+		return r, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), apiTokenKey{}, token)), nil
+}
+
+// APITokenFrom returns the personal token the request was made with.
+func APITokenFrom(r *http.Request) (models.APIToken, bool) {
+	token, ok := r.Context().Value(apiTokenKey{}).(models.APIToken)
+	return token, ok
+}
+
+// courseReadPaths are the course and catalog endpoints open to courses:read.
+var courseReadPaths = []string{
+	"/api/courses/", "/api/lessons/", "/api/questions/", "/api/enroll/",
+	"/api/home", "/api/certificates/keys",
+}
+
+// APITokenAllows reports whether the token scopes cover the request:
+// reading courses and the catalog needs courses:read, /api/studio/ needs
+// studio:write, /api/admin/ and everything else need admin. /api/account/
+// (sessions, tokens, 2FA) is closed to tokens whatever their scopes.
+func APITokenAllows(token models.APIToken, r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/api/account/"):
+		return false
+	case strings.HasPrefix(path, "/api/admin/"):
+		return token.HasScope(models.ScopeAdmin)
+	case strings.HasPrefix(path, "/api/studio/"):
+		return token.HasScope(models.ScopeStudioWrite)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		for _, prefix := range courseReadPaths {
+			if strings.HasPrefix(path, prefix) {
+				return token.HasScope(models.ScopeCoursesRead)
+			}
+		}
+	}
+	return token.HasScope(models.ScopeAdmin)
+}
+
+// sessionOnly rejects requests made with an API token: tokens, 2FA and
+// impersonation are managed from a browser session only, or a leaked token
+// would outlive its revocation.
+func sessionOnly(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := APITokenFrom(r); ok {
+		studioJSONError(w, "This action requires a browser session, API tokens are not accepted", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// GET /api/account/tokens
+func (h *Handler) ListAPITokensAPI(w http.ResponseWriter, r *http.Request) {
+	if !sessionOnly(w, r) {
+		return
+	}
+	userID, _ := h.GetAuthenticatedUserID(r)
+	list, err := storage.UserAPITokens(h.DB, userID)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// POST /api/account/tokens  {"name", "scopes": ["courses:read", ...]}
+//
+// Отвечает токеном целиком — больше его нигде не увидеть.
+func (h *Handler) CreateAPITokenAPI(w http.ResponseWriter, r *http.Request) {
+	if !sessionOnly(w, r) {
+		return
+	}
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	userID, _ := h.GetAuthenticatedUserID(r)
+	var user models.User
+	if err := h.DB.Select("id, role_id").First(&user, userID).Error; err != nil {
+		studioJSONError(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+	// Токен с правом admin обходит step-up, поэтому выдаётся только после него
+	for _, s := range req.Scopes {
+		if s == models.ScopeAdmin && !h.SteppedUp(r) {
+			studioJSONError(w, "step_up_required", http.StatusForbidden)
+			return
+		}
+	}
+
+	raw, token, err := storage.CreateAPIToken(h.DB, user, req.Name, req.Scopes)
+	switch {
+	case errors.Is(err, storage.ErrAPITokenName), errors.Is(err, storage.ErrAPITokenScopes):
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storage.ErrAPITokenAdminScope):
+		studioJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.logAction(userID, models.LogAPIToken, fmt.Sprintf("Создан токен «%s» (%s)", token.Name, token.Scopes), 0, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"token": raw, "data": token})
+}
+
+// DELETE /api/account/tokens/{id}
+func (h *Handler) RevokeAPITokenAPI(w http.ResponseWriter, r *http.Request) {
+	if !sessionOnly(w, r) {
+		return
+	}
+	userID, _ := h.GetAuthenticatedUserID(r)
+	tokenID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := storage.RevokeAPIToken(h.DB, userID, uint(tokenID))
+	if errors.Is(err, storage.ErrAPITokenNotFound) {
+		studioJSONError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.logAction(userID, models.LogAPIToken, fmt.Sprintf("Отозван токен #%d", tokenID), 0, 0)
+	w.WriteHeader(http.StatusNoContent)
+}
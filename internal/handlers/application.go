@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+const maxApplicationFields = 30
+
+// GET /api/courses/{id}/application-form — анкета для модального окна заявки
+func (h *Handler) GetApplicationFormAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"fields": storage.ApplicationFields(h.DB, uint(id)),
+	})
+}
+
+// POST /api/courses/{id}/application-form/upload — файл для ответа в анкете.
+// Лежит в папке пользователя для этого курса: чужой файл ответом не станет.
+func (h *Handler) UploadApplicationFileAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var n int64
+	h.DB.Model(&models.ApplicationField{}).Where("course_id = ? AND type = ?", id, "file").Count(&n)
+	if n == 0 {
+		studioJSONError(w, "The application form has no file fields", http.StatusNotFound)
+		return
+	}
+	saveUpload(w, r, storage.ApplicationUploadDir(uint(id), userID))
+}
+
+// GET /api/studio/courses/{id}/application-form
+func (h *Handler) StudioGetApplicationFormAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"fields": storage.ApplicationFields(h.DB, uint(id)),
+	})
+}
+
+// PUT /api/studio/courses/{id}/application-form — заменяет анкету целиком.
+// Поля с id обновляются, без id — создаются, отсутствующие — удаляются.
+// Уже полученные ответы не меняются: подпись поля хранится вместе с ответом.
+func (h *Handler) StudioUpdateApplicationFormAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		Fields []struct {
+			ID       uint     `json:"id"`
+			Type     string   `json:"type"`
+			Label    string   `json:"label"`
+			Help     string   `json:"help"`
+			Required bool     `json:"required"`
+			Options  []string `json:"options"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(input.Fields) > maxApplicationFields {
+		studioJSONError(w, "Too many fields (max 30)", http.StatusBadRequest)
+		return
+	}
+
+	fields := make([]models.ApplicationField, 0, len(input.Fields))
+	for i, f := range input.Fields {
+		field := models.ApplicationField{
+			ID:       f.ID,
+			CourseID: uint(id),
+			Order:    i,
+			Type:     f.Type,
+			Label:    strings.TrimSpace(f.Label),
+			Help:     strings.TrimSpace(f.Help),
+			Required: f.Required,
+		}
+		if field.Label == "" {
+			studioJSONError(w, "Field label is required", http.StatusBadRequest)
+			return
+		}
+		switch f.Type {
+		case "text", "textarea", "file":
+		case "choice", "multichoice":
+			var options []string
+			for _, o := range f.Options {
+				if o = strings.TrimSpace(o); o != "" {
+					options = append(options, o)
+				}
+			}
+			if len(options) == 0 {
+				studioJSONError(w, "Choice fields need at least one option", http.StatusBadRequest)
+				return
+			}
+			field.Options, _ = json.Marshal(options)
+		default:
+			studioJSONError(w, "Invalid field type", http.StatusBadRequest)
+			return
+		}
+		fields = append(fields, field)
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		keep := []uint{0}
+		for i := range fields {
+			if fields[i].ID != 0 {
+				// Чужие id не обновляем — такое поле создаётся заново
+				var n int64
+				tx.Model(&models.ApplicationField{}).Where("id = ? AND course_id = ?", fields[i].ID, id).Count(&n)
+				if n == 0 {
+					fields[i].ID = 0
+				}
+			}
+			if err := tx.Save(&fields[i]).Error; err != nil {
+				return err
+			}
+			keep = append(keep, fields[i].ID)
+		}
+		return tx.Where("course_id = ? AND id NOT IN ?", id, keep).Delete(&models.ApplicationField{}).Error
+	})
+	if err != nil {
+		studioJSONError(w, "Failed to save form", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"fields": fields})
+}
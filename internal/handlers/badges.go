@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// ─────────────────────────────────────────────
+// Open Badges 3.0 (Verifiable Credentials)
+// ─────────────────────────────────────────────
+
+var badgeContext = []string{
+	"https://www.w3.org/ns/credentials/v2",
+	"https://purl.imsglobal.org/spec/ob/v3p0/context-3.0.3.json",
+}
+
+func badgeIssuerURL() string { return siteBaseURL() + "/badges/issuer" }
+
+func badgeAchievementURL(courseID uint) string {
+	return fmt.Sprintf("%s/badges/achievements/%d", siteBaseURL(), courseID)
+}
+
+func badgeCredentialURL(code string) string {
+	return siteBaseURL() + "/badges/credentials/" + code
+}
+
+// badgeIssuer is the issuer Profile; embedded into credentials and served
+// on its own at /badges/issuer.
+func badgeIssuer() map[string]any {
+	base := siteBaseURL()
+	return map[string]any{
+		"id":   badgeIssuerURL(),
+		"type": []string{"Profile"},
+		"name": "CoursePlatform",
+		"url":  base,
+		"image": map[string]any{
+			"id":   base + "/static/logo-icon.svg",
+			"type": "Image",
+		},
+	}
+}
+
+// badgeAchievement describes what completing a course means.
+func badgeAchievement(c models.Course) map[string]any {
+	id := badgeAchievementURL(c.ID)
+	a := map[string]any{
+		"id":              id,
+		"type":            []string{"Achievement"},
+		"achievementType": "Course",
+		"name":            c.Title,
+		"description":     c.Description,
+		"criteria": map[string]any{
+			"id":        fmt.Sprintf("%s/course/%d/learn", siteBaseURL(), c.ID),
+			"narrative": "Complete every lesson of the course «" + c.Title + "».",
+		},
+		"creator": badgeIssuer(),
+		"resultDescription": []map[string]any{{
+			"id":         id + "#grade",
+			"type":       []string{"ResultDescription"},
+			"name":       "Quiz score",
+			"resultType": "Percent",
+		}},
+	}
+	if c.Language != "" {
+		a["inLanguage"] = c.Language
+	}
+	if c.ImageURL != "" {
+		img := c.ImageURL
+		if strings.HasPrefix(img, "/") {
+			img = siteBaseURL() + img
+		}
+		a["image"] = map[string]any{"id": img, "type": "Image"}
+	}
+	return a
+}
+
+// badgeCredential builds the OpenBadgeCredential for a certificate. The
+// learner is identified by a salted hash of their email, as wallets expect.
+func badgeCredential(cert models.Certificate) map[string]any {
+	sum := sha256.Sum256([]byte(strings.ToLower(cert.User.Email) + cert.Code))
+	subject := map[string]any{
+		"type":        []string{"AchievementSubject"},
+		"achievement": badgeAchievement(cert.Course),
+		"identifier": []map[string]any{{
+			"type":         "IdentityObject",
+			"identityHash": "sha256$" + hex.EncodeToString(sum[:]),
+			"identityType": "emailAddress",
+			"hashed":       true,
+			"salt":         cert.Code,
+		}},
+	}
+	if cert.Grade != nil {
+		subject["result"] = []map[string]any{{
+			"type":              []string{"Result"},
+			"resultDescription": badgeAchievementURL(cert.CourseID) + "#grade",
+			"value":             strconv.Itoa(*cert.Grade),
+		}}
+	}
+
+	return map[string]any{
+		"@context":          badgeContext,
+		"id":                badgeCredentialURL(cert.Code),
+		"type":              []string{"VerifiableCredential", "OpenBadgeCredential"},
+		"name":              cert.Course.Title + " — " + cert.User.Name,
+		"issuer":            badgeIssuer(),
+		"validFrom":         cert.IssuedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		"credentialSubject": subject,
+	}
+}
+
+func writeBadgeJSON(w http.ResponseWriter, r *http.Request, v any, filename string) {
+	w.Header().Set("Content-Type", "application/ld+json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if filename != "" && r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// GET /badges/issuer — профиль издателя
+func (h *Handler) HandleBadgeIssuer(w http.ResponseWriter, r *http.Request) {
+	profile := badgeIssuer()
+	profile["@context"] = badgeContext
+	writeBadgeJSON(w, r, profile, "")
+}
+
+// GET /badges/achievements/{id} — описание достижения (badge class) курса
+func (h *Handler) HandleBadgeAchievement(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var course models.Course
+	if err := h.DB.Where("is_published = ? AND (admin_status = ? OR admin_status = ?)", true, "approved", "").
+		First(&course, id).Error; err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	a := badgeAchievement(course)
+	a["@context"] = badgeContext
+	writeBadgeJSON(w, r, a, "")
+}
+
+func (h *Handler) loadBadgeCertificate(w http.ResponseWriter, code string) (models.Certificate, bool) {
+	var cert models.Certificate
+	if err := h.DB.Preload("User").Preload("Course").
+		Where("code = ?", code).First(&cert).Error; err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return cert, false
+	}
+	if cert.Revoked() {
+		http.Error(w, "Credential revoked", http.StatusGone)
+		return cert, false
+	}
+	return cert, true
+}
+
+// GET /badges/credentials/{code} — OpenBadgeCredential в JSON-LD
+func (h *Handler) HandleBadgeCredential(w http.ResponseWriter, r *http.Request) {
+	cert, ok := h.loadBadgeCertificate(w, mux.Vars(r)["code"])
+	if !ok {
+		return
+	}
+	writeBadgeJSON(w, r, badgeCredential(cert), fmt.Sprintf("badge-%s.json", cert.Code[:min(8, len(cert.Code))]))
+}
+
+// GET /badges/credentials/{code}/jwt — тот же credential, подписанный ключом
+// платформы (VC-JWT, EdDSA); ключи — /.well-known/jwks.json
+func (h *Handler) HandleBadgeCredentialJWT(w http.ResponseWriter, r *http.Request) {
+	cert, ok := h.loadBadgeCertificate(w, mux.Vars(r)["code"])
+	if !ok {
+		return
+	}
+
+	claims := badgeCredential(cert)
+	claims["iss"] = badgeIssuerURL()
+	claims["jti"] = badgeCredentialURL(cert.Code)
+	claims["nbf"] = cert.IssuedAt.Unix()
+
+	token, err := storage.SignCredentialJWT(h.DB, claims, siteBaseURL()+"/.well-known/jwks.json")
+	if err != nil {
+		log.Printf("HandleBadgeCredentialJWT: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vc+jwt")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="badge-%s.jwt"`, cert.Code[:min(8, len(cert.Code))]))
+	}
+	w.Write([]byte(token))
+}
+
+// linkedInAddURL returns the "Add to profile" link for a certificate.
+func linkedInAddURL(cert models.Certificate) string {
+	q := url.Values{}
+	q.Set("startTask", "CERTIFICATION_NAME")
+	q.Set("name", cert.Course.Title)
+	q.Set("organizationName", "CoursePlatform")
+	q.Set("issueYear", strconv.Itoa(cert.IssuedAt.Year()))
+	q.Set("issueMonth", strconv.Itoa(int(cert.IssuedAt.Month())))
+	q.Set("certUrl", siteBaseURL()+"/certificate/"+cert.Code)
+	q.Set("certId", cert.Code)
+	return "https://www.linkedin.com/profile/add?" + q.Encode()
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+const (
+	maxImportSize = 2 << 20 // 2 MB
+	maxImportRows = 5000
+)
+
+// POST /api/studio/courses/{id}/enrollments/import
+func (h *Handler) StudioImportEnrollmentsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var course models.Course
+	if err := h.DB.First(&course, id).Error; err != nil {
+		studioJSONError(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if course.AuthorID != userID {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	h.ImportEnrollmentsCSV(w, r, course, userID)
+}
+
+// ImportEnrollmentsCSV reads a CSV of learners from the request — either a
+// multipart "file" field or a raw text/csv body — and enrolls them on the
+// course. Columns: email, optional name, optional cohort; a header row is
+// detected by an "email" cell. A "cohort" form/query value applies to rows
+// without their own. Responds with a per-row report.
+func (h *Handler) ImportEnrollmentsCSV(w http.ResponseWriter, r *http.Request, course models.Course, actorID uint) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var src io.Reader = r.Body
+	defaultCohort := strings.TrimSpace(r.URL.Query().Get("cohort"))
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxImportSize); err != nil {
+			studioJSONError(w, "File too large (max 2 MB)", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			studioJSONError(w, "Missing file field", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		src = file
+		if c := strings.TrimSpace(r.FormValue("cohort")); c != "" {
+			defaultCohort = c
+		}
+	}
+
+	rows, err := parseEnrollmentCSV(src, defaultCohort)
+	if err != nil {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := storage.BulkEnroll(h.DB, course, rows, actorID)
+
+	summary := map[string]int{"enrolled": 0, "already_enrolled": 0, "invited": 0, "error": 0}
+	for _, res := range results {
+		summary[res.Result]++
+	}
+	h.logAction(actorID, models.LogBulkEnrollment,
+		fmt.Sprintf("Курс #%d: %d строк, %d записано, %d приглашено", course.ID, len(rows), summary["enrolled"], summary["invited"]),
+		course.ID, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":            len(rows),
+		"enrolled":         summary["enrolled"],
+		"already_enrolled": summary["already_enrolled"],
+		"invited":          summary["invited"],
+		"errors":           summary["error"],
+		"rows":             results,
+	})
+}
+
+func parseEnrollmentCSV(src io.Reader, defaultCohort string) ([]storage.BulkEnrollRow, error) {
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+
+	col := map[string]int{"email": 0, "name": 1, "cohort": 2}
+	start := 0
+	if len(records) > 0 && strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(records[0][0], "\ufeff")), "email") {
+		col = map[string]int{"email": -1, "name": -1, "cohort": -1}
+		for i, cell := range records[0] {
+			key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff")))
+			if _, ok := col[key]; ok {
+				col[key] = i
+			}
+		}
+		start = 1
+	}
+
+	cell := func(rec []string, key string) string {
+		i := col[key]
+		if i < 0 || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	var rows []storage.BulkEnrollRow
+	for i := start; i < len(records); i++ {
+		email := cell(records[i], "email")
+		if email == "" {
+			continue
+		}
+		cohort := cell(records[i], "cohort")
+		if cohort == "" {
+			cohort = defaultCohort
+		}
+		rows = append(rows, storage.BulkEnrollRow{
+			Line:   i + 1,
+			Email:  email,
+			Name:   cell(records[i], "name"),
+			Cohort: cohort,
+		})
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("CSV contains no emails")
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("too many rows (max %d)", maxImportRows)
+	}
+	return rows, nil
+}
@@ -151,7 +151,7 @@ func (h *Handler) buildCabinetData(userID uint) CabinetData {
 	}
 
 	for _, e := range enrollments {
-		if e.Status == "pending" || e.Status == "rejected" {
+		if e.Status != models.EnrollmentApproved {
 			d.Pending = append(d.Pending, e)
 			continue
 		}
@@ -198,7 +198,7 @@ func (h *Handler) buildCabinetData(userID uint) CabinetData {
 		var avgRating float64
 		h.DB.Model(&models.Review{}).
 			Select("COALESCE(AVG(rating), 0)").
-			Where("course_id = ?", c.ID).
+			Where("course_id = ? AND status = ?", c.ID, models.ContentPublished).
 			Scan(&avgRating)
 
 		d.AuthoredCourses = append(d.AuthoredCourses, AuthoredCourseView{
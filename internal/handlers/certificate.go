@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/certpdf"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+var hexColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+var (
+	errCertificateRevoked    = errors.New("certificate has been revoked")
+	errCertificateSuperseded = errors.New("certificate has been reissued, this version is no longer current")
+	errCertificateUnknown    = errors.New("certificate not found")
+)
+
+// GET /certificate/{code}/pdf — PDF-версия сертификата
+func (h *Handler) HandleCertificatePDF(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+
+	var cert models.Certificate
+	if err := h.DB.Preload("User").Preload("Course.Author").
+		Where("code = ?", code).First(&cert).Error; err != nil {
+		http.Error(w, "Сертификат не найден", http.StatusNotFound)
+		return
+	}
+	if cert.Revoked() {
+		http.Error(w, "Сертификат отозван", http.StatusGone)
+		return
+	}
+
+	lang := h.DetectLang(r)
+	if l := r.URL.Query().Get("lang"); i18n.IsSupported(l) {
+		lang = l
+	}
+
+	data := certpdf.Data{
+		Learner:  cert.User.Name,
+		Course:   cert.Course.Title,
+		Author:   cert.Course.Author.Name,
+		IssuedAt: cert.IssuedAt,
+		Grade:    cert.Grade,
+		Code:     cert.Code,
+		URL:      siteBaseURL() + "/certificate/" + cert.Code,
+		Lang:     lang,
+	}
+	h.writeCertificatePDF(w, data, storage.CertificateTemplateFor(h.DB, cert.CourseID),
+		fmt.Sprintf("certificate-%s.pdf", cert.Code[:min(8, len(cert.Code))]))
+}
+
+func (h *Handler) writeCertificatePDF(w http.ResponseWriter, data certpdf.Data, tpl models.CertificateTemplate, filename string) {
+	body, err := certpdf.Render(data, tpl)
+	if err != nil {
+		log.Printf("certificate pdf: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
+
+// GET /api/studio/courses/{id}/certificate-template
+func (h *Handler) StudioGetCertificateTemplateAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"template": storage.CertificateTemplateFor(h.DB, uint(id)),
+		"layouts":  certpdf.Layouts,
+	})
+}
+
+// PUT /api/studio/courses/{id}/certificate-template
+func (h *Handler) StudioUpdateCertificateTemplateAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var input models.CertificateTemplate
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := validateCertificateTemplate(&input); err != nil {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tpl := storage.CertificateTemplateFor(h.DB, uint(id))
+	tpl.Layout = input.Layout
+	tpl.AccentColor = input.AccentColor
+	tpl.Heading = input.Heading
+	tpl.Body = input.Body
+	tpl.SignerName = input.SignerName
+	tpl.SignerTitle = input.SignerTitle
+	tpl.ShowGrade = input.ShowGrade
+	if err := h.DB.Save(&tpl).Error; err != nil {
+		studioJSONError(w, "Failed to save template", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tpl)
+}
+
+// GET /api/studio/courses/{id}/certificate-template/preview — PDF с примерными данными.
+// Параметры запроса (layout, accent_color, ...) позволяют посмотреть несохранённые настройки.
+func (h *Handler) StudioPreviewCertificateAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var course models.Course
+	if err := h.DB.Preload("Author").First(&course, id).Error; err != nil {
+		studioJSONError(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if course.AuthorID != userID {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	tpl := storage.CertificateTemplateFor(h.DB, course.ID)
+	q := r.URL.Query()
+	if q.Has("layout") {
+		tpl.Layout = q.Get("layout")
+		tpl.AccentColor = q.Get("accent_color")
+		tpl.Heading = q.Get("heading")
+		tpl.Body = q.Get("body")
+		tpl.SignerName = q.Get("signer_name")
+		tpl.SignerTitle = q.Get("signer_title")
+		tpl.ShowGrade = q.Get("show_grade") == "true"
+		if err := validateCertificateTemplate(&tpl); err != nil {
+			studioJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var author models.User
+	h.DB.Select("name").First(&author, userID)
+
+	grade := 95
+	data := certpdf.Data{
+		Learner:  author.Name,
+		Course:   course.Title,
+		Author:   course.Author.Name,
+		IssuedAt: time.Now(),
+		Grade:    &grade,
+		Code:     "preview",
+		URL:      siteBaseURL() + "/certificate/preview",
+		Lang:     h.DetectLang(r),
+	}
+	h.writeCertificatePDF(w, data, tpl, "certificate-preview.pdf")
+}
+
+func validateCertificateTemplate(t *models.CertificateTemplate) error {
+	t.Heading = strings.TrimSpace(t.Heading)
+	t.Body = strings.TrimSpace(t.Body)
+	t.SignerName = strings.TrimSpace(t.SignerName)
+	t.SignerTitle = strings.TrimSpace(t.SignerTitle)
+
+	if t.Layout == "" {
+		t.Layout = "classic"
+	}
+	valid := false
+	for _, l := range certpdf.Layouts {
+		valid = valid || l == t.Layout
+	}
+	if !valid {
+		return fmt.Errorf("layout must be one of: %s", strings.Join(certpdf.Layouts, ", "))
+	}
+	if t.AccentColor == "" {
+		t.AccentColor = models.DefaultCertificateTemplate(0).AccentColor
+	}
+	if !hexColorRe.MatchString(t.AccentColor) {
+		return fmt.Errorf("accent_color must look like #4f46e5")
+	}
+	for _, s := range []string{t.Heading, t.Body, t.SignerName, t.SignerTitle} {
+		if len([]rune(s)) > 120 {
+			return fmt.Errorf("text fields are limited to 120 characters")
+		}
+	}
+	return nil
+}
+
+// ─────────────────────────────────────────────
+// Signed certificates
+// ─────────────────────────────────────────────
+
+// GET /.well-known/jwks.json — открытые ключи для офлайн-проверки подписей
+func (h *Handler) GetCertificateKeysAPI(w http.ResponseWriter, r *http.Request) {
+	keys, err := storage.PublicKeys(h.DB)
+	if err != nil {
+		log.Printf("GetCertificateKeysAPI: %v", err)
+		studioJSONError(w, "Failed to load keys", http.StatusInternalServerError)
+		return
+	}
+
+	// Отозванные ключи не публикуются, только их идентификаторы
+	published := make([]storage.JWK, 0, len(keys))
+	revoked := []string{}
+	for _, k := range keys {
+		if k.Status == models.SigningKeyRevoked {
+			revoked = append(revoked, k.Kid)
+			continue
+		}
+		published = append(published, k)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys":    published,
+		"revoked": revoked,
+	})
+}
+
+// GET /certificate/{code}/signed — подписанный сертификат (JWS) для передачи работодателю
+func (h *Handler) HandleSignedCertificate(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+
+	var cert models.Certificate
+	if err := h.DB.Where("code = ?", code).First(&cert).Error; err != nil {
+		studioJSONError(w, "Certificate not found", http.StatusNotFound)
+		return
+	}
+	if cert.Revoked() {
+		studioJSONError(w, "Certificate has been revoked", http.StatusGone)
+		return
+	}
+	// Сертификаты, выданные до появления подписей, подписываются при первом запросе
+	if cert.Signed == "" {
+		if err := storage.SignCertificate(h.DB, &cert, siteBaseURL()); err != nil {
+			log.Printf("HandleSignedCertificate: %v", err)
+			studioJSONError(w, "Failed to sign certificate", http.StatusInternalServerError)
+			return
+		}
+	}
+	payload, _, err := storage.VerifyCertificateToken(h.DB, cert.Signed)
+	if errors.Is(err, storage.ErrKeyRevoked) {
+		// Ключ скомпрометирован — переподписываем действующим
+		if err := storage.SignCertificate(h.DB, &cert, siteBaseURL()); err != nil {
+			studioJSONError(w, "Failed to sign certificate", http.StatusInternalServerError)
+			return
+		}
+		payload, _, err = storage.VerifyCertificateToken(h.DB, cert.Signed)
+	}
+	if err != nil {
+		log.Printf("HandleSignedCertificate: %v", err)
+		studioJSONError(w, "Failed to sign certificate", http.StatusInternalServerError)
+		return
+	}
+
+	base := siteBaseURL()
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="certificate-%s.json"`, cert.Code[:min(8, len(cert.Code))]))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(map[string]interface{}{
+		"token":      cert.Signed,
+		"kid":        cert.KeyID,
+		"payload":    payload,
+		"jwks_url":   base + "/.well-known/jwks.json",
+		"verify_url": base + "/api/certificates/verify",
+	})
+}
+
+// POST /api/certificates/verify — проверка подписи и текущего статуса сертификата.
+// Тело: {"token": "..."} или сам токен текстом. valid=true только для
+// статуса active: отозванный, перевыпущенный или неизвестный — недействителен.
+func (h *Handler) VerifyCertificateAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		studioJSONError(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	token := strings.TrimSpace(string(body))
+	var req struct {
+		Token string `json:"token"`
+	}
+	if strings.HasPrefix(token, "{") {
+		if err := json.Unmarshal(body, &req); err != nil {
+			studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+
+	payload, key, err := storage.VerifyCertificateToken(h.DB, token)
+	resp := map[string]interface{}{}
+	if key.KeyID != "" {
+		resp["kid"] = key.KeyID
+		resp["key_status"] = key.Status
+	}
+	if err == nil || errors.Is(err, storage.ErrKeyRevoked) {
+		resp["payload"] = payload
+
+		// Подпись подлинная — сверяем с текущим состоянием сертификата
+		status := "unknown"
+		var cert models.Certificate
+		if h.DB.Where("code = ?", payload.Code).First(&cert).Error == nil {
+			switch {
+			case cert.Revoked():
+				status = "revoked"
+				resp["revoked_at"] = cert.RevokedAt
+				resp["revoke_reason"] = cert.RevokeReason
+			case cert.Signed != "" && cert.Signed != strings.TrimSpace(token):
+				status = "superseded" // сертификат перевыпущен, актуальна новая версия
+			default:
+				status = "active"
+			}
+		}
+		resp["status"] = status
+		// Действительна только актуальная версия: подпись перевыпущенного
+		// или неизвестного сертификата подлинная, но на него нельзя полагаться
+		if err == nil {
+			switch status {
+			case "revoked":
+				err = errCertificateRevoked
+			case "superseded":
+				err = errCertificateSuperseded
+			case "unknown":
+				err = errCertificateUnknown
+			}
+		}
+	}
+	resp["valid"] = err == nil
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ─────────────────────────────────────────────
+// Revocation and reissue
+// ─────────────────────────────────────────────
+
+// GET /api/studio/courses/{id}/certificates — выданные сертификаты курса с историей
+func (h *Handler) StudioGetCourseCertificatesAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var certs []models.Certificate
+	if err := h.DB.Preload("User").Preload("History", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at asc")
+	}).Preload("History.Actor").
+		Where("course_id = ?", id).Order("issued_at desc").Find(&certs).Error; err != nil {
+		studioJSONError(w, "Failed to load certificates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(certs)
+}
+
+// POST /api/studio/courses/{id}/certificates/{cert_id}/revoke  {"reason": "..."}
+// POST /api/studio/courses/{id}/certificates/{cert_id}/reissue {"reason": "..."}
+func (h *Handler) StudioChangeCertificateAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	certID, _ := strconv.Atoi(vars["cert_id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var cert models.Certificate
+	if err := h.DB.Where("id = ? AND course_id = ?", certID, id).First(&cert).Error; err != nil {
+		studioJSONError(w, "Certificate not found", http.StatusNotFound)
+		return
+	}
+	h.ChangeCertificate(w, r, cert, userID, vars["action"])
+}
+
+// ChangeCertificate revokes or reissues a certificate on behalf of actorID
+// and writes the updated record. Shared by the studio and admin APIs.
+func (h *Handler) ChangeCertificate(w http.ResponseWriter, r *http.Request, cert models.Certificate, actorID uint, action string) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len([]rune(req.Reason)) > 500 {
+		studioJSONError(w, "Reason is limited to 500 characters", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	var logAction string
+	switch action {
+	case "revoke":
+		if req.Reason == "" {
+			studioJSONError(w, "Reason is required", http.StatusBadRequest)
+			return
+		}
+		logAction = models.LogCertRevoked
+		err = storage.RevokeCertificate(h.DB, &cert, actorID, req.Reason)
+	case "reissue":
+		logAction = models.LogCertReissued
+		err = storage.ReissueCertificate(h.DB, &cert, actorID, req.Reason, siteBaseURL())
+	default:
+		studioJSONError(w, "Unknown action", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrAlreadyRevoked) {
+		studioJSONError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("ChangeCertificate %s: %v", action, err)
+		studioJSONError(w, "Failed to update certificate", http.StatusInternalServerError)
+		return
+	}
+
+	var learner models.User
+	h.DB.Select("name").First(&learner, cert.UserID)
+	details := fmt.Sprintf("%s (%s)", learner.Name, cert.Code[:min(8, len(cert.Code))])
+	if req.Reason != "" {
+		details += ": " + req.Reason
+	}
+	h.logAction(actorID, logAction, details, cert.CourseID, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+// CSRFHeader carries the token of the session in POST, PUT and DELETE API
+// requests. Page scripts read it from the csrfCookie (see template "csrf").
+const (
+	CSRFHeader = "X-CSRF-Token"
+	csrfCookie = "csrf_token"
+)
+
+// CSRFToken returns the token of the request session and keeps the cookie
+// the page scripts read it from in sync. A request without a session gets
+// none: it carries no login that a forged request could abuse.
+func (h *Handler) CSRFToken(w http.ResponseWriter, r *http.Request) string {
+	session, _ := h.Store.Get(r, "session")
+	if session.IsNew {
+		return ""
+	}
+	token := toString(session.Values["csrf_token"])
+	if token == "" {
+		token = newCSRFToken()
+		session.Values["csrf_token"] = token
+		session.Save(r, w)
+	}
+	if c, err := r.Cookie(csrfCookie); err != nil || c.Value != token {
+		setCSRFCookie(w, token)
+	}
+	return token
+}
+
+// ValidCSRF reports whether a state-changing request carries the token of
+// its session. Safe methods and requests without a session always pass.
+func (h *Handler) ValidCSRF(w http.ResponseWriter, r *http.Request) bool {
+	token := h.CSRFToken(w, r)
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(CSRFHeader)), []byte(token)) == 1
+}
+
+// CSRFFailed answers a request rejected by ValidCSRF.
+func CSRFFailed(w http.ResponseWriter) {
+	studioJSONError(w, "csrf_token_invalid", http.StatusForbidden)
+}
+
+func newCSRFToken() string { return randomHex(32) }
+
+// randomHex returns n random bytes as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func setCSRFCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   86400 * 7,
+		HttpOnly: false, // читается скриптом страницы
+		SameSite: http.SameSiteStrictMode,
+	})
+}
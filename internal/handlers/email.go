@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// SiteBaseURL is siteBaseURL exported for background services (e-mail).
+func SiteBaseURL() string {
+	return siteBaseURL()
+}
+
+// --- НАСТРОЙКИ ПИСЕМ ---
+
+// GET /api/email-preferences
+func (h *Handler) GetEmailPreferencesAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	p, err := storage.EmailPreferences(h.DB, userID)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeEmailPreferences(w, p)
+}
+
+// PUT /api/email-preferences  {"enrollment": true, "course_review": true, "certificate": false}
+// Категории, которых нет в теле, не меняются.
+func (h *Handler) UpdateEmailPreferencesAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req map[string]*bool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	p, err := storage.SetEmailPreferences(h.DB, userID, req)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	writeEmailPreferences(w, p)
+}
+
+func writeEmailPreferences(w http.ResponseWriter, p models.EmailPreference) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{
+		models.EmailEnrollment:   p.Allows(models.EmailEnrollment),
+		models.EmailCourseReview: p.Allows(models.EmailCourseReview),
+		models.EmailCertificate:  p.Allows(models.EmailCertificate),
+	})
+}
+
+// GET|POST /email/unsubscribe/{token}?c=enrollment|course_review|certificate|all
+//
+// GET только показывает форму подтверждения: почтовые сканеры открывают
+// ссылки из писем сами, и отписка по GET случилась бы без ведома человека.
+// Отписывает POST — кнопка формы или «отписка в один клик» из почтового
+// клиента (RFC 8058, тело List-Unsubscribe=One-Click).
+func (h *Handler) HandleEmailUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+	var p models.EmailPreference
+	var err error
+	if r.Method == http.MethodPost {
+		p, err = storage.UnsubscribeEmail(h.DB, token, r.URL.Query().Get("c"))
+		if r.PostFormValue("List-Unsubscribe") == "One-Click" {
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	} else {
+		p, err = storage.EmailPreferencesByToken(h.DB, token)
+	}
+
+	lang := h.DetectLang(r)
+	var user models.User
+	if err == nil && h.DB.Select("language").First(&user, p.UserID).Error == nil && i18n.IsSupported(user.Language) {
+		lang = user.Language
+	}
+
+	data := PageData{
+		Title:       i18n.T(lang, "email.unsubscribed_title"),
+		Description: i18n.T(lang, "email.unsubscribed_text"),
+		CurrentPath: r.URL.Path,
+		Lang:        lang,
+	}
+	switch {
+	case err != nil:
+		w.WriteHeader(http.StatusNotFound)
+		data.Title = i18n.T(lang, "email.unsubscribe_invalid")
+		data.Description = ""
+	case r.Method != http.MethodPost:
+		data.Title = i18n.T(lang, "email.unsubscribe_confirm_title")
+		data.Description = i18n.T(lang, "email.unsubscribe_confirm_text")
+		data.ConfirmAction = r.URL.RequestURI()
+	}
+	h.Tmpl.ExecuteTemplate(w, "emailUnsubscribe", data)
+}
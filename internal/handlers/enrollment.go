@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+// ─────────────────────────────────────────────
+// LEARNER ENROLLMENT APIs  ({id} — ID курса)
+// ─────────────────────────────────────────────
+
+// GET /api/enroll/{id} — своя заявка на курс вместе с историей
+func (h *Handler) GetMyEnrollmentAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var enrollment models.Enrollment
+	if err := h.DB.Preload("Course").Preload("Answers").
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Where("user_id = ? AND course_id = ?", userID, courseID).
+		First(&enrollment).Error; err != nil {
+		studioJSONError(w, "Enrollment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// POST /api/enroll/{id}/unenroll — ученик покидает курс или отзывает заявку
+func (h *Handler) UnenrollAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&req) // тело необязательно
+
+	var enrollment models.Enrollment
+	if err := h.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error; err != nil {
+		studioJSONError(w, "Enrollment not found", http.StatusNotFound)
+		return
+	}
+	switch enrollment.Status {
+	case models.EnrollmentApproved, models.EnrollmentPending, models.EnrollmentWaitlisted:
+	default:
+		studioJSONError(w, "Nothing to leave", http.StatusConflict)
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "left by learner"
+	}
+	if err := storage.SetEnrollmentStatus(h.DB, &enrollment, models.EnrollmentUnenrolled, userID, reason); err != nil {
+		studioJSONError(w, "Failed to unenroll", http.StatusInternalServerError)
+		return
+	}
+	h.logAction(userID, models.LogUnenrolled, fmt.Sprintf("Курс #%d", courseID), uint(courseID), 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"request_status": enrollment.Status})
+}
+
+// POST /api/enroll/{id}/rerequest — повторная заявка после отказа, ухода или истечения срока
+func (h *Handler) RerequestEnrollmentAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var req struct {
+		Reason  string                           `json:"reason"`
+		Answers []storage.ApplicationAnswerInput `json:"answers"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	var enrollment models.Enrollment
+	if err := h.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error; err != nil {
+		studioJSONError(w, "Enrollment not found", http.StatusNotFound)
+		return
+	}
+	if !CanRerequest(enrollment.Status) {
+		studioJSONError(w, "Enrollment is still active", http.StatusConflict)
+		return
+	}
+	answers, err := storage.ValidateApplication(storage.ApplicationFields(h.DB, enrollment.CourseID), userID, req.Answers)
+	if err != nil {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Select("id, email").First(&user, userID).Error; err != nil {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := storage.RerequestEnrollment(h.DB, &enrollment, user, strings.TrimSpace(req.Reason)); err != nil {
+		studioJSONError(w, "Failed to re-request", http.StatusInternalServerError)
+		return
+	}
+	if err := storage.SaveApplicationAnswers(h.DB, enrollment.ID, answers); err != nil {
+		studioJSONError(w, "Failed to save answers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"request_status": enrollment.Status})
+}
+
+// CanRerequest reports whether a learner may apply again from this status.
+func CanRerequest(status string) bool {
+	switch status {
+	case models.EnrollmentRejected, models.EnrollmentUnenrolled, models.EnrollmentExpired:
+		return true
+	}
+	return false
+}
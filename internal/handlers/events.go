@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/realtime"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// GET /api/events?course=ID — поток Server-Sent Events.
+//
+// Пользователь получает события своего канала, администратор — ещё и общего
+// канала админов; с параметром course — комментарии и реакции курса, если
+// курс ему доступен (см. canWatchCourse).
+func (h *Handler) EventsStream(w http.ResponseWriter, r *http.Request) {
+	roleID, userID := h.GetUserRoleID(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	channels := []string{realtime.UserChannel(userID)}
+	if roleID >= models.RoleAdmin {
+		channels = append(channels, realtime.AdminsChannel)
+	}
+	if courseID, _ := strconv.Atoi(r.URL.Query().Get("course")); courseID > 0 {
+		if !h.canWatchCourse(roleID, userID, uint(courseID)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		channels = append(channels, realtime.CourseChannel(uint(courseID)))
+	}
+
+	events, cancel := h.Events.Subscribe(channels...)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx не должен буферизовать поток
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	// Комментарий раз в 25 секунд не даёт прокси закрыть соединение
+	ping := time.NewTicker(25 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e := <-events:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// PublishNotification tells the recipient's open pages about a new
+// notification so the bell updates without polling.
+func (h *Handler) PublishNotification(n models.Notification) {
+	h.Events.Publish(realtime.Event{
+		Type: "notification",
+		Data: map[string]interface{}{"kind": n.Kind, "subject": n.Subject},
+	}, realtime.UserChannel(n.UserID))
+}
+
+// publishCourseEvent sends e to everyone watching the course and to its
+// author.
+func (h *Handler) publishCourseEvent(courseID uint, e realtime.Event) {
+	if courseID == 0 {
+		return
+	}
+	channels := []string{realtime.CourseChannel(courseID)}
+	var course models.Course
+	if h.DB.Select("id, author_id").First(&course, courseID).Error == nil && course.AuthorID != 0 {
+		channels = append(channels, realtime.UserChannel(course.AuthorID))
+	}
+	h.Events.Publish(e, channels...)
+}
+
+// canWatchCourse: события курса видят админы, автор и ассистенты, слушатели
+// с действующей записью, а открытого опубликованного курса — все.
+func (h *Handler) canWatchCourse(roleID, userID, courseID uint) bool {
+	if roleID >= models.RoleAdmin || storage.IsCourseStaff(h.DB, userID, courseID) {
+		return true
+	}
+	var course models.Course
+	if err := h.DB.Select("id, is_open, is_published, admin_status").First(&course, courseID).Error; err != nil {
+		return false
+	}
+	published := course.IsPublished && (course.AdminStatus == "approved" || course.AdminStatus == "")
+	return published && course.IsOpen || storage.HasActiveEnrollment(h.DB, userID, courseID)
+}
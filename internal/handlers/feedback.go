@@ -2,17 +2,24 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/realtime"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
 )
 
 // --- КОММЕНТАРИИ К УРОКАМ ---
 
-// POST /api/lessons/{id}/comments
+// POST /api/lessons/{id}/comments  {"content": "...", "parent_id": 0}
 func (h *Handler) AddCommentAPI(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	lessonID, _ := strconv.Atoi(vars["id"])
@@ -23,63 +30,159 @@ func (h *Handler) AddCommentAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Content string `json:"content"`
+	comment := models.Comment{
+		UserID:   userID,
+		LessonID: uint(lessonID),
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if !h.createComment(w, r, &comment) {
 		return
 	}
 
-	if req.Content == "" {
-		http.Error(w, "Content is required", http.StatusBadRequest)
+	h.logAction(userID, models.LogCommentAdded,
+		fmt.Sprintf("Урок #%d", lessonID),
+		0, uint(lessonID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// GET /api/lessons/{id}/comments?page=1&limit=20&sort=newest|oldest|popular
+func (h *Handler) GetCommentsAPI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	lessonID, _ := strconv.Atoi(vars["id"])
+	h.writeCommentThreads(w, r, storage.CommentScope{LessonID: uint(lessonID)})
+}
+
+// --- КОММЕНТАРИИ К КУРСАМ ---
+
+// POST /api/courses/{id}/comments  {"content": "...", "parent_id": 0}
+func (h *Handler) AddCourseCommentAPI(w http.ResponseWriter, r *http.Request) {
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	_, userID := h.GetUserRoleID(r)
+
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	comment := models.Comment{
 		UserID:   userID,
-		LessonID: uint(lessonID),
-		Content:  req.Content,
+		CourseID: uint(courseID),
 	}
-
-	if err := h.DB.Create(&comment).Error; err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	if !h.createComment(w, r, &comment) {
 		return
 	}
 
-	h.DB.Preload("User").First(&comment, comment.ID)
 	h.logAction(userID, models.LogCommentAdded,
-		fmt.Sprintf("Урок #%d", lessonID),
-		0, uint(lessonID))
+		fmt.Sprintf("Курс #%d", courseID),
+		uint(courseID), 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(comment)
 }
 
-// GET /api/lessons/{id}/comments
-func (h *Handler) GetCommentsAPI(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	lessonID, _ := strconv.Atoi(vars["id"])
+// GET /api/courses/{id}/comments?page=1&limit=20&sort=newest|oldest|popular
+func (h *Handler) GetCourseCommentsAPI(w http.ResponseWriter, r *http.Request) {
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	h.writeCommentThreads(w, r, storage.CommentScope{CourseID: uint(courseID)})
+}
+
+// createComment reads the request body into c and stores it, as a reply
+// when parent_id is given. Errors are written to w.
+func (h *Handler) createComment(w http.ResponseWriter, r *http.Request, c *models.Comment) bool {
+	var req struct {
+		Content  string `json:"content"`
+		ParentID uint   `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return false
+	}
+
+	c.Content = strings.TrimSpace(req.Content)
+	if c.Content == "" {
+		http.Error(w, "Content is required", http.StatusBadRequest)
+		return false
+	}
+	if h.isRestricted(w, c.UserID) {
+		return false
+	}
+
+	courseID := c.CourseID
+	if c.LessonID > 0 {
+		courseID, _ = storage.LessonCourseID(h.DB, c.LessonID)
+	}
+	c.Status = storage.InitialContentStatus(h.DB, c.Content, c.UserID, courseID)
 
-	var comments []models.Comment
-	if err := h.DB.Preload("User").Where("lesson_id = ?", lessonID).Order("created_at desc").Find(&comments).Error; err != nil {
+	err := storage.CreateComment(h.DB, c, req.ParentID)
+	if errors.Is(err, storage.ErrBadParent) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+
+	h.notifyComment(c, courseID)
+	if c.Status == models.ContentPublished {
+		h.publishCourseEvent(courseID, realtime.Event{Type: "comment.created", Data: map[string]interface{}{
+			"comment_id": c.ID,
+			"course_id":  courseID,
+			"lesson_id":  c.LessonID,
+			"parent_id":  c.ParentID,
+		}})
+	}
+	h.DB.Preload("User").Preload("Mentions.User").First(c, c.ID)
+	return true
+}
+
+// notifyComment stores the @mentions of a comment and sends the
+// notifications it owes once published (storage.NotifyCommentPublished).
+func (h *Handler) notifyComment(c *models.Comment, courseID uint) {
+	mentions := storage.ResolveMentions(h.DB, storage.ParseMentionHandles(c.Content), courseID)
+	storage.SaveMentions(h.DB, c, mentions)
+	storage.NotifyCommentPublished(h.DB, *c)
+}
+
+func (h *Handler) writeCommentThreads(w http.ResponseWriter, r *http.Request, scope storage.CommentScope) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	comments, total, err := storage.CommentThreads(h.DB, scope, query.Get("sort"), page, limit)
+	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  comments,
+		"total": total,
+		"page":  page,
+		"pages": int(math.Ceil(float64(total) / float64(limit))),
+	})
 }
 
-// --- КОММЕНТАРИИ К КУРСАМ ---
-
-// POST /api/courses/{id}/comments
-func (h *Handler) AddCourseCommentAPI(w http.ResponseWriter, r *http.Request) {
-	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+// PUT /api/comments/{id} — исправить свой комментарий
+func (h *Handler) UpdateCommentAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
 	_, userID := h.GetUserRoleID(r)
 
-	if userID == 0 {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	var comment models.Comment
+	if err := h.DB.First(&comment, id).Error; err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if comment.UserID != userID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -90,44 +193,67 @@ func (h *Handler) AddCourseCommentAPI(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-
+	req.Content = strings.TrimSpace(req.Content)
 	if req.Content == "" {
 		http.Error(w, "Content is required", http.StatusBadRequest)
 		return
 	}
 
-	comment := models.Comment{
-		UserID:   userID,
-		CourseID: uint(courseID),
-		Content:  req.Content,
-	}
-
-	if err := h.DB.Create(&comment).Error; err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	if h.isRestricted(w, userID) {
 		return
 	}
 
-	h.DB.Preload("User").First(&comment, comment.ID)
-	h.logAction(userID, models.LogCommentAdded,
-		fmt.Sprintf("Курс #%d", courseID),
-		uint(courseID), 0)
+	if req.Content != comment.Content {
+		courseID := comment.CourseID
+		if comment.LessonID > 0 {
+			courseID, _ = storage.LessonCourseID(h.DB, comment.LessonID)
+		}
+		now := time.Now()
+		updates := map[string]interface{}{
+			"content":   req.Content,
+			"edited_at": &now,
+		}
+		// Правка не должна обходить фильтр; скрытое модератором остаётся скрытым
+		if comment.Status == models.ContentPublished {
+			updates["status"] = storage.InitialContentStatus(h.DB, req.Content, userID, courseID)
+		}
+		if err := h.DB.Model(&comment).Updates(updates).Error; err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		comment.Content = req.Content
+		if status, ok := updates["status"].(string); ok {
+			comment.Status = status
+		}
+		h.notifyComment(&comment, courseID)
+	}
 
+	h.DB.Preload("User").Preload("Mentions.User").First(&comment, comment.ID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(comment)
 }
 
-// GET /api/courses/{id}/comments
-func (h *Handler) GetCourseCommentsAPI(w http.ResponseWriter, r *http.Request) {
-	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+// DELETE /api/comments/{id} — удалить свой комментарий (мягкое удаление;
+// ответы на него остаются в ветке)
+func (h *Handler) DeleteCommentAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	_, userID := h.GetUserRoleID(r)
 
-	var comments []models.Comment
-	if err := h.DB.Preload("User").Where("course_id = ?", courseID).Order("created_at desc").Find(&comments).Error; err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	var comment models.Comment
+	if err := h.DB.First(&comment, id).Error; err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if comment.UserID != userID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
+	if err := h.DB.Delete(&comment).Error; err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // --- ОТЗЫВЫ ---
@@ -156,6 +282,29 @@ func (h *Handler) AddReviewAPI(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Rating must be between 1 and 5", http.StatusBadRequest)
 		return
 	}
+	if h.isRestricted(w, userID) {
+		return
+	}
+
+	var course models.Course
+	if err := h.DB.Select("id, review_min_progress").First(&course, courseID).Error; err != nil {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if course.ReviewMinProgress > 0 {
+		if progress := storage.CourseProgressPercent(h.DB, userID, course.ID); progress < course.ReviewMinProgress {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":    "Complete more of the course to leave a review",
+				"required": course.ReviewMinProgress,
+				"progress": progress,
+			})
+			return
+		}
+	}
+
+	status := storage.InitialContentStatus(h.DB, req.Content, userID, uint(courseID))
 
 	var review models.Review
 	result := h.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&review)
@@ -163,6 +312,9 @@ func (h *Handler) AddReviewAPI(w http.ResponseWriter, r *http.Request) {
 	if result.RowsAffected > 0 {
 		review.Rating = req.Rating
 		review.Content = req.Content
+		if review.Status != models.ContentHidden {
+			review.Status = status
+		}
 		h.DB.Save(&review)
 	} else {
 		review = models.Review{
@@ -170,6 +322,7 @@ func (h *Handler) AddReviewAPI(w http.ResponseWriter, r *http.Request) {
 			CourseID: uint(courseID),
 			Rating:   req.Rating,
 			Content:  req.Content,
+			Status:   status,
 		}
 		h.DB.Create(&review)
 	}
@@ -183,17 +336,147 @@ func (h *Handler) AddReviewAPI(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(review)
 }
 
-// GET /api/courses/{id}/reviews
+// GET /api/courses/{id}/reviews?page=1&limit=20&sort=newest|helpful|rating_desc|rating_asc
 func (h *Handler) GetReviewsAPI(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	courseID, _ := strconv.Atoi(vars["id"])
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	_, userID := h.GetUserRoleID(r)
+	page, limit := pageParams(r)
 
-	var reviews []models.Review
-	if err := h.DB.Preload("User").Where("course_id = ?", courseID).Order("created_at desc").Find(&reviews).Error; err != nil {
+	reviews, total, err := storage.FindReviews(h.DB, uint(courseID), r.URL.Query().Get("sort"), page, limit)
+	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	storage.MarkHelpfulVotes(h.DB, userID, reviews)
+	summary, _ := storage.CourseRatingSummary(h.DB, uint(courseID))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(reviews)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":    reviews,
+		"total":   total,
+		"page":    page,
+		"pages":   int(math.Ceil(float64(total) / float64(limit))),
+		"summary": summary,
+	})
+}
+
+// POST /api/reviews/{id}/helpful — отметить отзыв полезным (повторно — снять)
+func (h *Handler) ReviewHelpfulAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var review models.Review
+	if err := h.DB.Where("status = ?", models.ContentPublished).First(&review, id).Error; err != nil {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	if review.UserID == userID {
+		http.Error(w, "You cannot vote for your own review", http.StatusForbidden)
+		return
+	}
+
+	count, voted, err := storage.ToggleReviewHelpful(h.DB, userID, review.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"helpful_count": count, "helpful": voted})
+}
+
+// PUT /api/reviews/{id}/response  {"response": "..."} — ответ автора курса;
+// пустой текст удаляет ответ
+func (h *Handler) RespondReviewAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var review models.Review
+	if err := h.DB.First(&review, id).Error; err != nil {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	if !h.studioIsAuthor(userID, review.CourseID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Response string `json:"response"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Response = strings.TrimSpace(req.Response)
+	if len([]rune(req.Response)) > 2000 {
+		http.Error(w, "Response is limited to 2000 characters", http.StatusBadRequest)
+		return
+	}
+
+	if err := storage.SetReviewResponse(h.DB, &review, req.Response); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	h.DB.Preload("User").First(&review, review.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// --- ЖАЛОБЫ И ОГРАНИЧЕНИЯ ---
+
+// isRestricted writes 403 if the user is muted or banned from posting.
+func (h *Handler) isRestricted(w http.ResponseWriter, userID uint) bool {
+	rs, ok := storage.ActiveRestriction(h.DB, userID)
+	if !ok {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "You are not allowed to post",
+		"kind":   rs.Kind,
+		"until":  rs.Until,
+		"reason": rs.Reason,
+	})
+	return true
+}
+
+// POST /api/comments/{id}/report, POST /api/reviews/{id}/report  {"reason": "..."}
+func (h *Handler) ReportContentAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len([]rune(req.Reason)) > 500 {
+		http.Error(w, "Reason is limited to 500 characters", http.StatusBadRequest)
+		return
+	}
+
+	err := storage.ReportContent(h.DB, vars["type"], uint(id), userID, req.Reason)
+	switch {
+	case errors.Is(err, storage.ErrAlreadyReported):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
 }
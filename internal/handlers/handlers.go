@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
@@ -10,24 +11,29 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
-	"golang.org/x/oauth2"
 
+	"github.com/s/onlineCourse/internal/auth"
 	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/mail"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/realtime"
 	"github.com/s/onlineCourse/internal/storage"
 
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	DB     *gorm.DB
-	Store  *sessions.CookieStore
-	Config *oauth2.Config
-	Tmpl   *template.Template
+	DB        *gorm.DB
+	Store     sessions.Store // сессии в Postgres, см. internal/session
+	Providers *auth.Registry // OpenID Connect: Google и корпоративный SSO
+	Tmpl      *template.Template
+	Events    realtime.Broker
+	Mailer    mail.Sender
 }
 
-func NewHandler(db *gorm.DB, store *sessions.CookieStore, config *oauth2.Config) *Handler {
+func NewHandler(db *gorm.DB, store sessions.Store, providers *auth.Registry) *Handler {
 
 	funcMap := template.FuncMap{
 		"mod": func(a, b int) int { return a % b },
@@ -38,7 +44,8 @@ func NewHandler(db *gorm.DB, store *sessions.CookieStore, config *oauth2.Config)
 			}
 			return t.Format("02.01.2006 в 15:04")
 		},
-		"T": i18n.T,
+		"T":           i18n.T,
+		"linkedinURL": linkedInAddURL,
 		"ogLocale": func(lang string) string {
 			switch lang {
 			case "en":
@@ -64,10 +71,12 @@ func NewHandler(db *gorm.DB, store *sessions.CookieStore, config *oauth2.Config)
 	}
 
 	return &Handler{
-		DB:     db,
-		Store:  store,
-		Config: config,
-		Tmpl:   tmpl,
+		DB:        db,
+		Store:     store,
+		Providers: providers,
+		Tmpl:      tmpl,
+		Events:    realtime.NewMemoryBroker(),
+		Mailer:    mail.LogSender{},
 	}
 }
 
@@ -112,6 +121,12 @@ type PageData struct {
 	// User profile page
 	ProfileUser    *models.User
 	ProfileCourses []ProfileCourseView
+
+	// Login, registration and password pages
+	Auth AuthPageData
+
+	// Confirmation form of a link from an email (unsubscribe): POST target
+	ConfirmAction string
 }
 
 // DetectLang resolves the best language for a request.
@@ -142,7 +157,12 @@ func buildTransJSON(lang string) template.JS {
 	return template.JS(data)
 }
 
+// GetAuthenticatedUserID returns the user of the personal API token or, for
+// browser requests, of the session.
 func (h *Handler) GetAuthenticatedUserID(r *http.Request) (uint, bool) {
+	if token, ok := APITokenFrom(r); ok {
+		return token.UserID, true
+	}
 	session, _ := h.Store.Get(r, "session")
 	userIDValue := session.Values["user_id"]
 	userID, ok := userIDValue.(uint)
@@ -150,10 +170,7 @@ func (h *Handler) GetAuthenticatedUserID(r *http.Request) (uint, bool) {
 }
 
 func (h *Handler) GetUserRoleID(r *http.Request) (uint, uint) {
-	session, _ := h.Store.Get(r, "session")
-
-	userIDvalue := session.Values["user_id"]
-	userID, _ := userIDvalue.(uint)
+	userID, _ := h.GetAuthenticatedUserID(r)
 
 	roleID := models.RoleGuest
 
@@ -265,7 +282,7 @@ func (h *Handler) GetHomeDataAPI(w http.ResponseWriter, r *http.Request) {
 	var reviews []models.Review
 	if page == 1 && filter == "all" && search == "" {
 		h.DB.Preload("User").Preload("Course").
-			Where("rating >= ?", 4).
+			Where("rating >= ? AND status = ?", 4, models.ContentPublished).
 			Order("created_at desc").
 			Limit(6).
 			Find(&reviews)
@@ -331,6 +348,11 @@ func truncate(s string, maxLen int) string {
 }
 
 // logAction записывает действие пользователя в таблицу user_logs.
+// LogAction writes to the user journal; exported for the admin service.
+func (h *Handler) LogAction(userID uint, action, details string, courseID, lessonID uint) {
+	h.logAction(userID, action, details, courseID, lessonID)
+}
+
 func (h *Handler) logAction(userID uint, action, details string, courseID, lessonID uint) {
 	entry := models.UserLog{
 		UserID:   userID,
@@ -374,81 +396,143 @@ func (h *Handler) HandleProfile(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/cabinet", http.StatusMovedPermanently)
 }
 
-func (h *Handler) HandleGoogleLogin(w http.ResponseWriter, r *http.Request) {
-	url := h.Config.AuthCodeURL("random_state")
+// GET /auth/{provider}/login — переход на страницу входа IdP
+func (h *Handler) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.Providers.Get(mux.Vars(r)["provider"])
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// state защищает callback от подделки (CSRF), nonce — ID token от повтора
+	state, nonce := randomHex(16), randomHex(16)
+	url, err := provider.AuthCodeURL(r.Context(), state, nonce)
+	if err != nil {
+		log.Printf("HandleOIDCLogin: %v", err)
+		http.Error(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	session, _ := h.Store.Get(r, "session")
+	session.Values["oidc_state"] = state
+	session.Values["oidc_nonce"] = nonce
+	session.Save(r, w)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
-func (h *Handler) HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Query().Get("state") != "random_state" {
+// GET /auth/{provider}/callback
+//
+// Вошедший пользователь так привязывает к своему аккаунту ещё один способ
+// входа; остальные входят или регистрируются.
+func (h *Handler) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.Store.Get(r, "session")
+	state := toString(session.Values["oidc_state"])
+	nonce := toString(session.Values["oidc_nonce"])
+	delete(session.Values, "oidc_state")
+	delete(session.Values, "oidc_nonce")
+	session.Save(r, w)
+
+	provider, ok := h.Providers.Get(mux.Vars(r)["provider"])
+	if !ok || state == "" || subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("state")), []byte(state)) != 1 {
 		http.Error(w, "Invalid state", http.StatusUnauthorized)
 		return
 	}
 
-	code := r.URL.Query().Get("code")
-	token, err := h.Config.Exchange(context.Background(), code)
+	identity, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), nonce)
 	if err != nil {
-		http.Error(w, "Token exchange error", http.StatusBadRequest)
+		log.Printf("HandleOIDCCallback: %v", err)
+		http.Error(w, "Login failed", http.StatusBadRequest)
 		return
 	}
 
-	client := h.Config.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		http.Error(w, "Google API error", http.StatusBadRequest)
+	currentUserID, linking := h.GetAuthenticatedUserID(r)
+	userID, err := storage.SaveIdentity(h.DB, provider.Config.Name, identity, currentUserID)
+	switch {
+	case errors.Is(err, storage.ErrLinkRequiresLogin), errors.Is(err, storage.ErrNoEmail):
+		http.Redirect(w, r, "/login?error=link_account", http.StatusSeeOther)
 		return
-	}
-	defer resp.Body.Close()
-
-	var userInfo models.User
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		http.Error(w, "JSON decode error", http.StatusInternalServerError)
+	case errors.Is(err, storage.ErrIdentityTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "DB save error", http.StatusInternalServerError)
 		return
 	}
 
-	userID, err := storage.SaveUser(h.DB, userInfo)
-	if err != nil {
-		http.Error(w, "DB save error", http.StatusInternalServerError)
+	if linking {
+		http.Redirect(w, r, "/cabinet", http.StatusSeeOther)
 		return
 	}
+	http.Redirect(w, r, h.startLogin(w, r, userID, "Вход через "+provider.Config.DisplayName), http.StatusSeeOther)
+}
+
+// logIn starts the session of the user, whatever the sign-in method, and
+// syncs the language preference between the cookie and the account.
+func (h *Handler) logIn(w http.ResponseWriter, r *http.Request, userID uint, details string) {
+	var user models.User
+	h.DB.Select("id, email, name, picture, language").First(&user, userID)
 
 	// If a lang cookie was set before login, persist it to the user record.
 	if c, err := r.Cookie("lang"); err == nil && i18n.IsSupported(c.Value) {
 		h.DB.Model(&models.User{}).Where("id = ?", userID).Update("language", c.Value)
-	} else {
+	} else if i18n.IsSupported(user.Language) {
 		// Restore previously saved language preference to cookie.
-		var u models.User
-		if h.DB.Select("language").First(&u, userID).Error == nil && i18n.IsSupported(u.Language) {
-			http.SetCookie(w, &http.Cookie{
-				Name:     "lang",
-				Value:    u.Language,
-				Path:     "/",
-				MaxAge:   86400 * 365,
-				HttpOnly: false,
-				SameSite: http.SameSiteLaxMode,
-			})
-		}
-	}
+		http.SetCookie(w, &http.Cookie{
+			Name:     "lang",
+			Value:    user.Language,
+			Path:     "/",
+			MaxAge:   86400 * 365,
+			HttpOnly: false,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	// Новый токен сессии и CSRF при входе: токен, известный до входа,
+	// не должен дать доступ к аккаунту (session fixation).
+	session, _ := h.Store.Get(r, "session")
+	if session.ID != "" {
+		storage.DeleteSession(h.DB, session.ID)
+		session.ID = ""
+	}
+	h.endImpersonation(w, session, false)
+	// Вход начинается с чистых значений: подтверждение 2FA (mfa_at) и всё
+	// прочее от прежнего входа в этой сессии не переходит к новому аккаунту.
+	returnTo := session.Values["return_to"]
+	session.Values = make(map[interface{}]interface{})
+	if returnTo != nil {
+		session.Values["return_to"] = returnTo
+	}
+	token := newCSRFToken()
+	user.ID = userID
+	setSessionUser(session, user)
+	session.Values["csrf_token"] = token
+	if err := session.Save(r, w); err != nil {
+		log.Printf("logIn: save session: %v", err)
+	}
+	setCSRFCookie(w, token)
+
+	h.logAction(userID, models.LogLogin, details, 0, 0)
+}
 
+// popReturnTo returns the local path saved before a login redirect (e.g. an
+// invite link) and clears it; "/" when nothing was saved.
+func (h *Handler) popReturnTo(w http.ResponseWriter, r *http.Request) string {
 	session, _ := h.Store.Get(r, "session")
-	session.Values["user_id"] = userID
-	session.Values["email"] = userInfo.Email
-	session.Values["name"] = userInfo.Name
-	session.Values["picture_url"] = userInfo.Picture
-	session.Options = &sessions.Options{
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   86400 * 7,
+	path := toString(session.Values["return_to"])
+	if path == "" {
+		return "/"
 	}
+	delete(session.Values, "return_to")
 	session.Save(r, w)
-
-	h.logAction(userID, models.LogLogin, "Вход через Google", 0, 0)
-
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return "/"
+	}
+	return path
 }
 
 func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	session, _ := h.Store.Get(r, "session")
+	h.endImpersonation(w, session, false)
 	session.Options.MaxAge = -1
 	session.Save(r, w)
 	http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+)
+
+// «Просмотр от имени» для поддержки: админ видит сайт так, как его видит
+// ученик. В сессии user_id, почта, имя и аватар подменяются на ученика, а
+// impersonator_id хранит админа — строка сессии в базе остаётся за ним.
+// Пока режим включён, ничего изменить нельзя (middleware.Impersonation).
+
+// impersonationCookie tells the page scripts to show the banner; the state
+// itself lives in the session.
+const impersonationCookie = "impersonating"
+
+// Impersonator returns the admin who is viewing the site as the session user.
+func (h *Handler) Impersonator(r *http.Request) (uint, bool) {
+	if _, viaToken := APITokenFrom(r); viaToken {
+		return 0, false
+	}
+	session, _ := h.Store.Get(r, "session")
+	adminID, ok := session.Values["impersonator_id"].(uint)
+	return adminID, ok && adminID != 0
+}
+
+// setSessionUser makes user the user of the session.
+func setSessionUser(session *sessions.Session, user models.User) {
+	session.Values["user_id"] = user.ID
+	session.Values["email"] = user.Email
+	session.Values["name"] = user.Name
+	session.Values["picture_url"] = user.Picture
+}
+
+func setImpersonationCookie(w http.ResponseWriter, on bool) {
+	c := &http.Cookie{
+		Name:     impersonationCookie,
+		Value:    "1",
+		Path:     "/",
+		HttpOnly: false, // читается скриптом баннера
+		SameSite: http.SameSiteLaxMode,
+	}
+	if !on {
+		c.Value, c.MaxAge = "", -1
+	}
+	http.SetCookie(w, c)
+}
+
+// POST /api/admin/users/{id}/impersonate — начать просмотр от имени
+// пользователя. Админов и менеджеров так открыть нельзя.
+func (h *Handler) StartImpersonationAPI(w http.ResponseWriter, r *http.Request) {
+	if !sessionOnly(w, r) {
+		return
+	}
+	adminID, _ := h.GetAuthenticatedUserID(r)
+	targetID, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+
+	var target models.User
+	if err := h.DB.Select("id, email, name, picture, role_id").First(&target, targetID).Error; err != nil {
+		studioJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if target.ID == adminID {
+		studioJSONError(w, "cannot_impersonate_self", http.StatusBadRequest)
+		return
+	}
+	if target.RoleID >= models.RoleAdmin {
+		studioJSONError(w, "cannot_impersonate_staff", http.StatusForbidden)
+		return
+	}
+
+	session, _ := h.Store.Get(r, "session")
+	session.Values["impersonator_id"] = adminID
+	session.Values["impersonation_at"] = time.Now().Unix()
+	setSessionUser(session, target)
+	if err := session.Save(r, w); err != nil {
+		studioJSONError(w, "Session error", http.StatusInternalServerError)
+		return
+	}
+	setImpersonationCookie(w, true)
+	h.logAction(adminID, models.LogImpersonation,
+		fmt.Sprintf("Начат просмотр от имени %s (ID %d)", target.Email, target.ID), 0, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"redirect": "/my-courses"})
+}
+
+// POST /api/impersonation/stop — вернуться в свой аккаунт
+func (h *Handler) StopImpersonationAPI(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.Impersonator(r); !ok {
+		setImpersonationCookie(w, false)
+		studioJSONError(w, "not_impersonating", http.StatusConflict)
+		return
+	}
+	session, _ := h.Store.Get(r, "session")
+	h.endImpersonation(w, session, true)
+	if err := session.Save(r, w); err != nil {
+		studioJSONError(w, "Session error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"redirect": "/admin/users"})
+}
+
+// GET /api/impersonation — для баннера: от чьего имени открыт сайт
+func (h *Handler) ImpersonationStatusAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, ok := h.Impersonator(r); !ok {
+		setImpersonationCookie(w, false)
+		json.NewEncoder(w).Encode(map[string]bool{"active": false})
+		return
+	}
+	session, _ := h.Store.Get(r, "session")
+	name := toString(session.Values["name"])
+	email := toString(session.Values["email"])
+	lang := h.DetectLang(r)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"active":  true,
+		"user_id": session.Values["user_id"],
+		"name":    name,
+		"email":   email,
+		"banner":  strings.ReplaceAll(i18n.T(lang, "impersonation.banner"), "{name}", name+" <"+email+">"),
+		"stop":    i18n.T(lang, "impersonation.stop"),
+	})
+}
+
+// endImpersonation ends the "view as" mode of the session and writes the
+// stop event. With restore the admin becomes the session user again;
+// otherwise the caller is about to replace or destroy the session.
+func (h *Handler) endImpersonation(w http.ResponseWriter, session *sessions.Session, restore bool) {
+	adminID, ok := session.Values["impersonator_id"].(uint)
+	if !ok {
+		return
+	}
+	targetID, _ := session.Values["user_id"].(uint)
+	startedAt, _ := session.Values["impersonation_at"].(int64)
+	delete(session.Values, "impersonator_id")
+	delete(session.Values, "impersonation_at")
+	if restore {
+		var admin models.User
+		h.DB.Select("id, email, name, picture").First(&admin, adminID)
+		setSessionUser(session, admin)
+	}
+	setImpersonationCookie(w, false)
+	h.logAction(adminID, models.LogImpersonation,
+		fmt.Sprintf("Завершён просмотр от имени ID %d (%s)", targetID, time.Since(time.Unix(startedAt, 0)).Round(time.Second)), 0, 0)
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// ─────────────────────────────────────────────
+// STUDIO INVITE APIs  (author-scoped)
+// ─────────────────────────────────────────────
+
+// POST /api/studio/courses/{id}/invites
+func (h *Handler) StudioCreateInviteAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(courseID)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		Kind      string     `json:"kind"` // "link" | "code"
+		MaxUses   int        `json:"max_uses"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		Cohort    string     `json:"cohort"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if input.MaxUses < 0 {
+		studioJSONError(w, "max_uses must not be negative", http.StatusBadRequest)
+		return
+	}
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		studioJSONError(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	var token string
+	var err error
+	switch input.Kind {
+	case "link", "":
+		input.Kind = "link"
+		token, err = storage.NewInviteToken()
+	case "code":
+		token, err = storage.NewAccessCode()
+	default:
+		studioJSONError(w, "kind must be 'link' or 'code'", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	invite := models.CourseInvite{
+		CourseID:    uint(courseID),
+		CreatedByID: userID,
+		Kind:        input.Kind,
+		Token:       token,
+		MaxUses:     input.MaxUses,
+		ExpiresAt:   input.ExpiresAt,
+		Cohort:      strings.TrimSpace(input.Cohort),
+	}
+	if err := h.DB.Create(&invite).Error; err != nil {
+		studioJSONError(w, "Failed to create invite", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(inviteView(invite))
+}
+
+// GET /api/studio/courses/{id}/invites
+func (h *Handler) StudioGetInvitesAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(courseID)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var invites []models.CourseInvite
+	if err := h.DB.Where("course_id = ?", courseID).Order("created_at desc").Find(&invites).Error; err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]map[string]interface{}, 0, len(invites))
+	for _, inv := range invites {
+		views = append(views, inviteView(inv))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// DELETE /api/studio/invites/{id} — отзыв приглашения (уже выданные доступы сохраняются)
+func (h *Handler) StudioRevokeInviteAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var invite models.CourseInvite
+	if err := h.DB.First(&invite, id).Error; err != nil {
+		studioJSONError(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+	if !h.studioIsAuthor(userID, invite.CourseID) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if invite.RevokedAt == nil {
+		if err := h.DB.Model(&invite).Update("revoked_at", time.Now()).Error; err != nil {
+			studioJSONError(w, "Failed to revoke invite", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "revoked"})
+}
+
+// GET /api/studio/invites/{id}/redemptions
+func (h *Handler) StudioGetInviteRedemptionsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var invite models.CourseInvite
+	if err := h.DB.First(&invite, id).Error; err != nil {
+		studioJSONError(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+	if !h.studioIsAuthor(userID, invite.CourseID) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var redemptions []models.InviteRedemption
+	h.DB.Preload("User").Where("invite_id = ?", invite.ID).Order("created_at desc").Find(&redemptions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invite":      inviteView(invite),
+		"redemptions": redemptions,
+	})
+}
+
+// ─────────────────────────────────────────────
+// LEARNER: redeem
+// ─────────────────────────────────────────────
+
+// GET /invite/{token} — переход по ссылке-приглашению. Страница только
+// предлагает записаться: запись — POST /api/invites/redeem по кнопке, иначе
+// любая страница записала бы вошедшего пользователя через <img src=/invite/…>.
+func (h *Handler) HandleInviteLink(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		// Вернёмся сюда после входа
+		session, _ := h.Store.Get(r, "session")
+		session.Values["return_to"] = r.URL.Path
+		session.Save(r, w)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	var invite models.CourseInvite
+	var course models.Course
+	if h.DB.Where("token = ? AND kind = ?", token, "link").First(&invite).Error != nil ||
+		h.DB.Select("id, title").First(&course, invite.CourseID).Error != nil {
+		http.Error(w, inviteErrorMessage(storage.ErrInviteNotFound), http.StatusNotFound)
+		return
+	}
+	if storage.HasActiveEnrollment(h.DB, userID, course.ID) {
+		http.Redirect(w, r, fmt.Sprintf("/course/%d/learn", course.ID), http.StatusSeeOther)
+		return
+	}
+
+	lang := h.DetectLang(r)
+	h.Tmpl.ExecuteTemplate(w, "inviteConfirm", PageData{
+		Title:       i18n.T(lang, "invite.title"),
+		Description: course.Title,
+		CurrentPath: r.URL.Path,
+		Lang:        lang,
+		TransJSON:   BuildTransJSON(lang),
+	})
+}
+
+// POST /api/invites/redeem — погашение кода доступа {"code"} или ссылки-приглашения {"token"}
+func (h *Handler) RedeemAccessCodeAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code  string `json:"code"`
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Code+req.Token) == "" {
+		studioJSONError(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		token = storage.NormalizeAccessCode(req.Code)
+	}
+
+	enrollment, _, err := h.redeemInvite(userID, token)
+	if err != nil {
+		studioJSONError(w, inviteErrorMessage(err), inviteErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"course_id":      enrollment.CourseID,
+		"request_status": enrollment.Status,
+		"cohort":         enrollment.Cohort,
+	})
+}
+
+func (h *Handler) redeemInvite(userID uint, token string) (models.Enrollment, models.CourseInvite, error) {
+	var user models.User
+	if err := h.DB.Select("id, email").First(&user, userID).Error; err != nil {
+		return models.Enrollment{}, models.CourseInvite{}, err
+	}
+	enrollment, invite, err := storage.RedeemInvite(h.DB, token, user)
+	if err == nil {
+		h.logAction(userID, models.LogInviteRedeemed, fmt.Sprintf("Приглашение #%d", invite.ID), invite.CourseID, 0)
+	}
+	return enrollment, invite, err
+}
+
+func inviteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrInviteNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrInviteExpired), errors.Is(err, storage.ErrInviteExhausted), errors.Is(err, storage.ErrInviteRedeemed):
+		return http.StatusGone
+	case errors.Is(err, storage.ErrCourseFull):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func inviteErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrInviteNotFound):
+		return "Invite not found"
+	case errors.Is(err, storage.ErrInviteExpired):
+		return "Invite expired or revoked"
+	case errors.Is(err, storage.ErrInviteExhausted):
+		return "Invite usage limit reached"
+	case errors.Is(err, storage.ErrInviteRedeemed):
+		return "Invite already used"
+	case errors.Is(err, storage.ErrCourseFull):
+		return "Course is full"
+	default:
+		return "Server error"
+	}
+}
+
+// inviteView adds the shareable URL and a derived state to an invite.
+func inviteView(inv models.CourseInvite) map[string]interface{} {
+	state := "active"
+	switch {
+	case inv.RevokedAt != nil:
+		state = "revoked"
+	case inv.ExpiresAt != nil && inv.ExpiresAt.Before(time.Now()):
+		state = "expired"
+	case inv.MaxUses > 0 && inv.UsedCount >= inv.MaxUses:
+		state = "exhausted"
+	}
+	v := map[string]interface{}{
+		"id":         inv.ID,
+		"course_id":  inv.CourseID,
+		"kind":       inv.Kind,
+		"token":      inv.Token,
+		"max_uses":   inv.MaxUses,
+		"used_count": inv.UsedCount,
+		"expires_at": inv.ExpiresAt,
+		"cohort":     inv.Cohort,
+		"revoked_at": inv.RevokedAt,
+		"created_at": inv.CreatedAt,
+		"state":      state,
+	}
+	if inv.Kind == "link" {
+		v["url"] = siteBaseURL() + "/invite/" + inv.Token
+	}
+	return v
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/s/onlineCourse/internal/jobs"
+	"github.com/s/onlineCourse/internal/models"
+)
+
+type certificateJob struct {
+	UserID   uint `json:"user_id"`
+	CourseID uint `json:"course_id"`
+}
+
+// RegisterJobs adds the background jobs run on behalf of handlers.
+func (h *Handler) RegisterJobs(p *jobs.Pool) {
+	p.Handle(models.JobIssueCertificate, func(ctx context.Context, payload json.RawMessage) error {
+		var job certificateJob
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return err
+		}
+		return h.issueCertificateIfComplete(job.UserID, job.CourseID)
+	})
+}
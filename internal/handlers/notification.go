@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// --- УВЕДОМЛЕНИЯ ---
+
+// GET /api/notifications?page=1&limit=20&unread=1
+func (h *Handler) GetNotificationsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	page, limit := pageParams(r)
+
+	list, total, err := storage.Notifications(h.DB, userID, r.URL.Query().Get("unread") == "1", page, limit)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":   list,
+		"total":  total,
+		"page":   page,
+		"pages":  int(math.Ceil(float64(total) / float64(limit))),
+		"unread": storage.UnreadNotifications(h.DB, userID),
+	})
+}
+
+// GET /api/notifications/unread-count
+func (h *Handler) UnreadNotificationsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"count": storage.UnreadNotifications(h.DB, userID)})
+}
+
+// POST /api/notifications/{id}/read, POST /api/notifications/read-all
+func (h *Handler) MarkNotificationsReadAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var ids []uint
+	if idStr, found := mux.Vars(r)["id"]; found {
+		id, _ := strconv.Atoi(idStr)
+		ids = append(ids, uint(id))
+	}
+	if err := storage.MarkNotificationsRead(h.DB, userID, ids...); err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"count": storage.UnreadNotifications(h.DB, userID)})
+}
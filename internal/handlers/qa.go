@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// ─────────────────────────────────────────────
+// Lesson Q&A
+// ─────────────────────────────────────────────
+
+func pageParams(r *http.Request) (page, limit int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
+
+func (h *Handler) writeQuestions(w http.ResponseWriter, r *http.Request, f storage.QuestionFilter) {
+	_, userID := h.GetUserRoleID(r)
+	page, limit := pageParams(r)
+
+	questions, total, err := storage.FindQuestions(h.DB, f, page, limit)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	storage.MarkQuestionVotes(h.DB, userID, questions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  questions,
+		"total": total,
+		"page":  page,
+		"pages": int(math.Ceil(float64(total) / float64(limit))),
+	})
+}
+
+// GET /api/lessons/{id}/questions?sort=votes|newest|oldest&q=&unanswered=1&page=
+func (h *Handler) GetLessonQuestionsAPI(w http.ResponseWriter, r *http.Request) {
+	lessonID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	q := r.URL.Query()
+	h.writeQuestions(w, r, storage.QuestionFilter{
+		LessonID:   uint(lessonID),
+		Search:     strings.TrimSpace(q.Get("q")),
+		Unanswered: q.Get("unanswered") == "1",
+		Sort:       q.Get("sort"),
+	})
+}
+
+// GET /api/courses/{id}/questions?q=...&unanswered=1 — поиск по вопросам всего курса
+func (h *Handler) SearchCourseQuestionsAPI(w http.ResponseWriter, r *http.Request) {
+	courseID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	q := r.URL.Query()
+	h.writeQuestions(w, r, storage.QuestionFilter{
+		CourseIDs:  []uint{uint(courseID)},
+		Search:     strings.TrimSpace(q.Get("q")),
+		Unanswered: q.Get("unanswered") == "1",
+		Sort:       q.Get("sort"),
+	})
+}
+
+// POST /api/lessons/{id}/questions  {"title": "...", "body": "..."}
+func (h *Handler) AskQuestionAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	if userID == 0 {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	lessonID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	courseID, err := storage.LessonCourseID(h.DB, uint(lessonID))
+	if err != nil {
+		studioJSONError(w, "Lesson not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Title == "" {
+		studioJSONError(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+	if h.isRestricted(w, userID) {
+		return
+	}
+	if len([]rune(req.Title)) > 200 {
+		studioJSONError(w, "Title is limited to 200 characters", http.StatusBadRequest)
+		return
+	}
+
+	question := models.Question{
+		CourseID: courseID,
+		LessonID: uint(lessonID),
+		UserID:   userID,
+		Title:    req.Title,
+		Body:     req.Body,
+	}
+	if err := h.DB.Create(&question).Error; err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.DB.Preload("User").First(&question, question.ID)
+	h.logAction(userID, models.LogQuestionAsked, question.Title, courseID, uint(lessonID))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(question)
+}
+
+// GET /api/questions/{id} — вопрос с ответами (принятый ответ первым)
+func (h *Handler) GetQuestionAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	question, ok := h.loadQuestion(w, r)
+	if !ok {
+		return
+	}
+	if err := storage.LoadAnswers(h.DB, &question, userID); err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	questions := []models.Question{question}
+	storage.MarkQuestionVotes(h.DB, userID, questions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"question":   questions[0],
+		"can_accept": storage.IsCourseStaff(h.DB, userID, question.CourseID),
+	})
+}
+
+// POST /api/questions/{id}/answers  {"body": "..."}
+func (h *Handler) AnswerQuestionAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	if userID == 0 {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	question, ok := h.loadQuestion(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" {
+		studioJSONError(w, "Answer is required", http.StatusBadRequest)
+		return
+	}
+	if h.isRestricted(w, userID) {
+		return
+	}
+
+	answer := models.Answer{QuestionID: question.ID, UserID: userID, Body: req.Body}
+	if err := storage.CreateAnswer(h.DB, &answer); err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.DB.Preload("User").First(&answer, answer.ID)
+	answer.ByStaff = storage.IsCourseStaff(h.DB, userID, question.CourseID)
+	h.logAction(userID, models.LogAnswerAdded, question.Title, question.CourseID, question.LessonID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(answer)
+}
+
+// PUT /api/questions/{id}/accept  {"answer_id": 5} — 0 снимает отметку.
+// Доступно автору курса и ассистентам.
+func (h *Handler) AcceptAnswerAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	question, ok := h.loadQuestion(w, r)
+	if !ok {
+		return
+	}
+	if !storage.IsCourseStaff(h.DB, userID, question.CourseID) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		AnswerID uint `json:"answer_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	err := storage.AcceptAnswer(h.DB, &question, req.AnswerID)
+	if errors.Is(err, storage.ErrNotAnAnswer) {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"accepted_answer_id": question.AcceptedAnswerID})
+}
+
+// POST /api/questions/{id}/vote, POST /api/answers/{id}/vote — «полезно» (повторно — снять)
+func (h *Handler) VoteQAAPI(w http.ResponseWriter, r *http.Request) {
+	_, userID := h.GetUserRoleID(r)
+	if userID == 0 {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	targetType := vars["type"]
+
+	var n int64
+	if targetType == "answer" {
+		h.DB.Model(&models.Answer{}).Where("id = ?", id).Count(&n)
+	} else {
+		h.DB.Model(&models.Question{}).Where("id = ?", id).Count(&n)
+	}
+	if n == 0 {
+		studioJSONError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	count, voted, err := storage.ToggleQAVote(h.DB, userID, targetType, uint(id))
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"upvotes": count, "voted": voted})
+}
+
+func (h *Handler) loadQuestion(w http.ResponseWriter, r *http.Request) (models.Question, bool) {
+	var question models.Question
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := h.DB.Preload("User").First(&question, id).Error; err != nil {
+		studioJSONError(w, "Question not found", http.StatusNotFound)
+		return question, false
+	}
+	return question, true
+}
+
+// ─────────────────────────────────────────────
+// Studio: unanswered queue and assistants
+// ─────────────────────────────────────────────
+
+// GET /api/studio/questions/unanswered?course_id= — вопросы без ответа автора
+// или ассистента по всем курсам, где пользователь ведёт занятия
+func (h *Handler) StudioUnansweredQuestionsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var courseIDs []uint
+	h.DB.Model(&models.Course{}).Where("author_id = ?", userID).Pluck("id", &courseIDs)
+	var assisted []uint
+	h.DB.Model(&models.CourseAssistant{}).Where("user_id = ?", userID).Pluck("course_id", &assisted)
+	courseIDs = append(courseIDs, assisted...)
+
+	if id, _ := strconv.Atoi(r.URL.Query().Get("course_id")); id > 0 {
+		if !storage.IsCourseStaff(h.DB, userID, uint(id)) {
+			studioJSONError(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		courseIDs = []uint{uint(id)}
+	}
+	if len(courseIDs) == 0 {
+		courseIDs = []uint{0}
+	}
+
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "oldest" // дольше всех ждущие — первыми
+	}
+	h.writeQuestions(w, r, storage.QuestionFilter{
+		CourseIDs:  courseIDs,
+		Search:     strings.TrimSpace(r.URL.Query().Get("q")),
+		Unanswered: true,
+		Sort:       sort,
+	})
+}
+
+// GET /api/studio/courses/{id}/assistants
+func (h *Handler) StudioGetAssistantsAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var assistants []models.CourseAssistant
+	h.DB.Preload("User").Where("course_id = ?", id).Order("created_at asc").Find(&assistants)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assistants)
+}
+
+// POST /api/studio/courses/{id}/assistants  {"email": "..."}
+func (h *Handler) StudioAddAssistantAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	if err := h.DB.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(req.Email))).First(&user).Error; err != nil {
+		studioJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if user.ID == userID {
+		studioJSONError(w, "The author already manages the course", http.StatusBadRequest)
+		return
+	}
+
+	assistant := models.CourseAssistant{CourseID: uint(id), UserID: user.ID}
+	if err := h.DB.Where(assistant).FirstOrCreate(&assistant).Error; err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	assistant.User = user
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assistant)
+}
+
+// DELETE /api/studio/courses/{id}/assistants/{user_id}
+func (h *Handler) StudioRemoveAssistantAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"])
+	if !h.studioIsAuthor(userID, uint(id)) {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	h.DB.Where("course_id = ? AND user_id = ?", id, vars["user_id"]).Delete(&models.CourseAssistant{})
+	w.WriteHeader(http.StatusNoContent)
+}
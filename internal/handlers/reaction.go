@@ -8,6 +8,8 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/realtime"
+	"github.com/s/onlineCourse/internal/storage"
 )
 
 type reactionCountsResponse struct {
@@ -71,6 +73,17 @@ func (h *Handler) handleReact(w http.ResponseWriter, r *http.Request, targetType
 		fmt.Sprintf("%s %s #%d", req.Type, targetType, targetID),
 		courseID, lessonID)
 
+	if courseID == 0 {
+		courseID, _ = storage.LessonCourseID(h.DB, lessonID)
+	}
+	counts := h.reactionCounts(targetType, targetID, 0)
+	h.publishCourseEvent(courseID, realtime.Event{Type: "reaction", Data: map[string]interface{}{
+		"target_type": targetType,
+		"target_id":   targetID,
+		"likes":       counts.Likes,
+		"dislikes":    counts.Dislikes,
+	}})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(h.reactionCounts(targetType, targetID, userID))
 }
@@ -29,6 +29,7 @@ Disallow: /cabinet
 Disallow: /my-courses
 Disallow: /auth/
 Disallow: /logout
+Disallow: /invite/
 
 Sitemap: %s/sitemap.xml
 `, baseURL)
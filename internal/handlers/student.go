@@ -12,6 +12,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
 	"gorm.io/gorm"
 )
 
@@ -110,8 +111,7 @@ func (s *Handler) HandleCourseLearn(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 			return
 		}
-		var enrollment models.Enrollment
-		if err := s.DB.Where("user_id = ? AND course_id = ? AND status = ?", userID, courseID, "approved").First(&enrollment).Error; err != nil {
+		if !storage.HasActiveEnrollment(s.DB, userID, course.ID) {
 			http.Error(w, "Доступ запрещен или заявка не одобрена", http.StatusForbidden)
 			return
 		}
@@ -163,6 +163,7 @@ func (s *Handler) HandleCourseLearn(w http.ResponseWriter, r *http.Request) {
 		JSONLD:       JSONLDCourse(course, courseURL),
 		Course:       course,
 		IsAuthenticated: userID != 0,
+		UserID:          userID,
 		UserName:        toString(session.Values["name"]),
 		UserPictureURL:  toString(session.Values["picture_url"]),
 		DoneLessonsMap:  doneMap,
@@ -204,8 +205,7 @@ func (s *Handler) HandleLessonView(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 			return
 		}
-		var enrollment models.Enrollment
-		if err := s.DB.Where("user_id = ? AND course_id = ? AND status = ?", userID, courseID, "approved").First(&enrollment).Error; err != nil {
+		if !storage.HasActiveEnrollment(s.DB, userID, course.ID) {
 			http.Error(w, "Доступ запрещен или заявка не одобрена", http.StatusForbidden)
 			return
 		}
@@ -257,6 +257,7 @@ func (s *Handler) HandleLessonView(w http.ResponseWriter, r *http.Request) {
 		Course:       course,
 		Lesson:          lesson,
 		IsAuthenticated: userID != 0,
+		UserID:          userID,
 		NextLessonID:    nextID,
 		PrevLessonID:    prevID,
 		IsLessonDone:    isDone,
@@ -273,7 +274,8 @@ func (s *Handler) HandleLessonView(w http.ResponseWriter, r *http.Request) {
 }
 
 // issueCertificateIfComplete проверяет 100% прогресс и выдаёт сертификат если ещё не выдан.
-func (s *Handler) issueCertificateIfComplete(userID, courseID uint) {
+// Выполняется в фоновой задаче: ошибка означает повтор попытки.
+func (s *Handler) issueCertificateIfComplete(userID, courseID uint) error {
 	var totalLessons int64
 	s.DB.Model(&models.Lesson{}).
 		Joins("JOIN modules ON modules.id = lessons.module_id").
@@ -281,7 +283,7 @@ func (s *Handler) issueCertificateIfComplete(userID, courseID uint) {
 		Count(&totalLessons)
 
 	if totalLessons == 0 {
-		return
+		return nil
 	}
 
 	var doneLessons int64
@@ -290,19 +292,18 @@ func (s *Handler) issueCertificateIfComplete(userID, courseID uint) {
 		Count(&doneLessons)
 
 	if doneLessons < totalLessons {
-		return
+		return nil
 	}
 
 	// Не выдавать повторно
 	var existing models.Certificate
 	if s.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&existing).Error == nil {
-		return
+		return nil
 	}
 
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
-		log.Printf("issueCertificate rand: %v", err)
-		return
+		return fmt.Errorf("issueCertificate rand: %w", err)
 	}
 
 	cert := models.Certificate{
@@ -310,15 +311,27 @@ func (s *Handler) issueCertificateIfComplete(userID, courseID uint) {
 		CourseID: courseID,
 		Code:     hex.EncodeToString(b),
 		IssuedAt: time.Now(),
+		Grade:    storage.CourseGrade(s.DB, userID, courseID),
 	}
 	if err := s.DB.Create(&cert).Error; err != nil {
-		log.Printf("issueCertificate create: %v", err)
-		return
+		return fmt.Errorf("issueCertificate create: %w", err)
+	}
+	if err := storage.SignCertificate(s.DB, &cert, siteBaseURL()); err != nil {
+		// Сертификат уже выдан; подпись будет создана при первом запросе
+		log.Printf("issueCertificate sign: %v", err)
 	}
 
 	var course models.Course
 	s.DB.Select("title").First(&course, courseID)
 	s.logAction(userID, models.LogCourseComplete, course.Title, courseID, 0)
+	storage.Notify(s.DB, models.Notification{
+		UserID:  userID,
+		Kind:    models.NotifyCertificate,
+		Subject: course.Title,
+		Link:    "/certificate/" + cert.Code,
+	})
+	storage.DispatchCertificateIssued(s.DB, cert, siteBaseURL()+"/certificate/"+cert.Code)
+	return nil
 }
 
 // SaveQuizAttemptAPI — Сохранение ответа СРАЗУ (POST /api/course/{id}/lesson/{lesson_id}/quiz)
@@ -406,15 +419,38 @@ func (s *Handler) MarkLessonReadAPI(w http.ResponseWriter, r *http.Request) {
 	lessonID, _ := strconv.ParseUint(vars["lesson_id"], 10, 32)
 	_, userID := s.GetUserRoleID(r)
 
+	// Урок должен принадлежать курсу, а курс — быть доступен ученику:
+	// отметка уходит в вебхуки lesson.completed
+	if owner, err := storage.LessonCourseID(s.DB, uint(lessonID)); err != nil || owner != uint(courseID) {
+		http.Error(w, "Lesson not found", http.StatusNotFound)
+		return
+	}
+	var course models.Course
+	if err := s.DB.Select("id, is_open").First(&course, courseID).Error; err != nil {
+		http.Error(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if !course.IsOpen && !storage.HasActiveEnrollment(s.DB, userID, course.ID) {
+		http.Error(w, "Доступ запрещен или заявка не одобрена", http.StatusForbidden)
+		return
+	}
+
+	var progress models.LessonProgress
+	firstTime := s.DB.Where("user_id = ? AND lesson_id = ?", userID, lessonID).First(&progress).Error != nil || !progress.IsDone
+
 	s.DB.Where("user_id = ? AND lesson_id = ?", userID, lessonID).
 		Assign(models.LessonProgress{IsDone: true, UpdatedAt: time.Now()}).
 		FirstOrCreate(&models.LessonProgress{UserID: userID, LessonID: uint(lessonID), CourseID: uint(courseID)})
+	if firstTime {
+		storage.DispatchLessonCompleted(s.DB, userID, uint(courseID), uint(lessonID))
+	}
 
 	var lesson models.Lesson
 	s.DB.Select("title").First(&lesson, lessonID)
 	s.logAction(userID, models.LogLessonView, lesson.Title, uint(courseID), uint(lessonID))
 
-	s.issueCertificateIfComplete(userID, uint(courseID))
+	// Проверка прогресса и выдача сертификата — в фоновой задаче
+	storage.EnqueueJob(s.DB, models.JobIssueCertificate, certificateJob{UserID: userID, CourseID: uint(courseID)}, storage.JobOptions{})
 
 	w.WriteHeader(http.StatusOK)
 }
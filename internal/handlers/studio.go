@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -18,6 +19,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/s/onlineCourse/internal/i18n"
 	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/realtime"
+	"github.com/s/onlineCourse/internal/storage"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -242,6 +245,11 @@ func (h *Handler) StudioSubmitCourseAPI(w http.ResponseWriter, r *http.Request)
 		studioJSONError(w, "Failed to submit", http.StatusInternalServerError)
 		return
 	}
+	h.Events.Publish(realtime.Event{Type: "course.submitted", Data: map[string]interface{}{
+		"course_id": course.ID,
+		"title":     course.Title,
+	}}, realtime.AdminsChannel)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"admin_status": "pending_review"})
 }
@@ -606,7 +614,7 @@ func (h *Handler) StudioGetCourseEnrollmentsAPI(w http.ResponseWriter, r *http.R
 	const pageSize = 20
 	offset := (page - 1) * pageSize
 
-	q := h.DB.Model(&models.Enrollment{}).Preload("User").Where("course_id = ?", id)
+	q := h.DB.Model(&models.Enrollment{}).Preload("User").Preload("Answers").Where("course_id = ?", id)
 	if status := r.URL.Query().Get("status"); status != "" && status != "all" {
 		q = q.Where("status = ?", status)
 	}
@@ -648,17 +656,24 @@ func (h *Handler) StudioUpdateEnrollmentAPI(w http.ResponseWriter, r *http.Reque
 
 	var input struct {
 		Status string `json:"status"`
+		Reason string `json:"reason"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-	if input.Status != "approved" && input.Status != "rejected" && input.Status != "pending" {
+	switch input.Status {
+	case models.EnrollmentApproved, models.EnrollmentRejected, models.EnrollmentPending, models.EnrollmentWaitlisted:
+	default:
 		studioJSONError(w, "Invalid status", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.DB.Model(&models.Enrollment{}).Where("id = ?", id).Update("status", input.Status).Error; err != nil {
+	if err := storage.SetEnrollmentStatus(h.DB, &enrollment, input.Status, userID, strings.TrimSpace(input.Reason)); err != nil {
+		if errors.Is(err, storage.ErrCourseFull) {
+			studioJSONError(w, "Course is full", http.StatusConflict)
+			return
+		}
 		studioJSONError(w, "Failed to update enrollment", http.StatusInternalServerError)
 		return
 	}
@@ -666,6 +681,142 @@ func (h *Handler) StudioUpdateEnrollmentAPI(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(map[string]string{"status": input.Status})
 }
 
+// GET /api/studio/enrollments/{id}
+func (h *Handler) StudioGetEnrollmentAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var enrollment models.Enrollment
+	if err := h.DB.Preload("User").Preload("Course").Preload("Answers").
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Preload("History.Actor").
+		First(&enrollment, id).Error; err != nil {
+		studioJSONError(w, "Enrollment not found", http.StatusNotFound)
+		return
+	}
+	if enrollment.Course.AuthorID != userID {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// GET /api/studio/courses/{id}/enrollment-rules
+func (h *Handler) StudioGetEnrollmentRulesAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var course models.Course
+	if err := h.DB.First(&course, id).Error; err != nil {
+		studioJSONError(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if course.AuthorID != userID {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var waitlisted int64
+	h.DB.Model(&models.Enrollment{}).
+		Where("course_id = ? AND status = ?", course.ID, models.EnrollmentWaitlisted).
+		Count(&waitlisted)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"auto_approve":         course.AutoApprove,
+		"auto_approve_domains": course.AutoApproveDomains,
+		"max_seats":            course.MaxSeats,
+		"access_days":          course.AccessDays,
+		"review_min_progress":  course.ReviewMinProgress,
+		"taken_seats":          storage.TakenSeats(h.DB, course.ID),
+		"waitlisted":           waitlisted,
+	})
+}
+
+// PUT /api/studio/courses/{id}/enrollment-rules
+func (h *Handler) StudioUpdateEnrollmentRulesAPI(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.GetAuthenticatedUserID(r)
+	if !ok {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	var course models.Course
+	if err := h.DB.First(&course, id).Error; err != nil {
+		studioJSONError(w, "Course not found", http.StatusNotFound)
+		return
+	}
+	if course.AuthorID != userID {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var input struct {
+		AutoApprove        string `json:"auto_approve"`
+		AutoApproveDomains string `json:"auto_approve_domains"`
+		MaxSeats           int    `json:"max_seats"`
+		AccessDays         int    `json:"access_days"`
+		ReviewMinProgress  *int   `json:"review_min_progress"` // не прислан — не меняется
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if input.AutoApprove == "" {
+		input.AutoApprove = "none"
+	}
+	if input.AutoApprove != "none" && input.AutoApprove != "all" && input.AutoApprove != "domain" {
+		studioJSONError(w, "auto_approve must be 'none', 'all' or 'domain'", http.StatusBadRequest)
+		return
+	}
+	if input.AutoApprove == "domain" && strings.TrimSpace(input.AutoApproveDomains) == "" {
+		studioJSONError(w, "auto_approve_domains is required for domain rule", http.StatusBadRequest)
+		return
+	}
+	if input.MaxSeats < 0 || input.AccessDays < 0 {
+		studioJSONError(w, "max_seats and access_days must not be negative", http.StatusBadRequest)
+		return
+	}
+	if p := input.ReviewMinProgress; p != nil && (*p < 0 || *p > 100) {
+		studioJSONError(w, "review_min_progress must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]interface{}{
+		"auto_approve":         input.AutoApprove,
+		"auto_approve_domains": strings.TrimSpace(input.AutoApproveDomains),
+		"max_seats":            input.MaxSeats,
+		"access_days":          input.AccessDays,
+	}
+	// Порог отзывов добавлен позже остальных правил: клиенты, которые его не
+	// знают, не должны сбрасывать его в 0
+	if input.ReviewMinProgress != nil {
+		updates["review_min_progress"] = *input.ReviewMinProgress
+	}
+	if err := h.DB.Model(&course).Updates(updates).Error; err != nil {
+		studioJSONError(w, "Failed to update rules", http.StatusInternalServerError)
+		return
+	}
+
+	// Seats may have been added — let the waitlist in
+	course.MaxSeats = input.MaxSeats
+	course.AccessDays = input.AccessDays
+	storage.PromoteWaitlist(h.DB, course)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 // ─────────────────────────────────────────────
 // helpers
 // ─────────────────────────────────────────────
@@ -712,6 +863,12 @@ func (h *Handler) StudioUploadFileAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	saveUpload(w, r, "uploads")
+}
+
+// saveUpload stores the multipart "file" field in dir (under ./uploads) and
+// answers with its public URL.
+func saveUpload(w http.ResponseWriter, r *http.Request, dir string) {
 	const maxSize = 50 << 20 // 50 MB
 	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
 	if err := r.ParseMultipartForm(maxSize); err != nil {
@@ -738,7 +895,7 @@ func (h *Handler) StudioUploadFileAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.MkdirAll("uploads", 0755); err != nil {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		studioJSONError(w, "Server error", http.StatusInternalServerError)
 		return
 	}
@@ -750,7 +907,7 @@ func (h *Handler) StudioUploadFileAPI(w http.ResponseWriter, r *http.Request) {
 		}
 		return '_'
 	}, safeName)
-	dst := filepath.Join("uploads", safeName)
+	dst := filepath.Join(dir, safeName)
 
 	out, err := os.Create(dst)
 	if err != nil {
@@ -768,7 +925,7 @@ func (h *Handler) StudioUploadFileAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"filename": header.Filename,
-		"url":      "/uploads/" + safeName,
+		"url":      "/" + filepath.ToSlash(dst),
 		"size":     size,
 		"mime":     header.Header.Get("Content-Type"),
 	})
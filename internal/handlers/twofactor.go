@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/auth"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+const (
+	// adminStepUpTTL — как долго после ввода кода открыты админские маршруты
+	adminStepUpTTL = time.Hour
+	// maxSecondFactorAttempts неверных кодов — и вход начинается заново
+	maxSecondFactorAttempts = 5
+	totpIssuer              = "CoursePlatform"
+)
+
+// startLogin logs the user in or, with two-factor authentication on, keeps
+// them pending in the session until the code is entered. It returns the
+// page to go to next.
+func (h *Handler) startLogin(w http.ResponseWriter, r *http.Request, userID uint, details string) string {
+	var user models.User
+	h.DB.Select("id, totp_enabled").First(&user, userID)
+	if user.TOTPEnabled {
+		session, _ := h.Store.Get(r, "session")
+		session.Values["mfa_user_id"] = userID
+		session.Values["mfa_details"] = details
+		delete(session.Values, "mfa_attempts")
+		session.Save(r, w)
+		return "/login/2fa"
+	}
+	h.logIn(w, r, userID, details)
+	return h.popReturnTo(w, r)
+}
+
+// SteppedUp reports whether the session confirmed the second factor
+// recently enough for admin routes.
+func (h *Handler) SteppedUp(r *http.Request) bool {
+	session, _ := h.Store.Get(r, "session")
+	at, _ := session.Values["mfa_at"].(int64)
+	return time.Since(time.Unix(at, 0)) < adminStepUpTTL
+}
+
+// RequireStepUp answers an admin request that needs the second factor:
+// pages go to the code form (or to the 2FA setup when it is off), API calls
+// get 403 with the reason.
+func (h *Handler) RequireStepUp(w http.ResponseWriter, r *http.Request, user models.User) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		reason := "step_up_required"
+		if !user.TOTPEnabled {
+			reason = "two_factor_setup_required"
+		}
+		studioJSONError(w, reason, http.StatusForbidden)
+		return
+	}
+	if !user.TOTPEnabled {
+		http.Redirect(w, r, "/cabinet?two_factor=required#two-factor", http.StatusSeeOther)
+		return
+	}
+	session, _ := h.Store.Get(r, "session")
+	session.Values["return_to"] = r.URL.RequestURI()
+	session.Save(r, w)
+	http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+}
+
+// POST /api/auth/2fa  {"code"} — код из приложения или резервный код:
+// завершает вход с 2FA либо подтверждает вошедшего для админских маршрутов.
+func (h *Handler) SecondFactorAPI(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	session, _ := h.Store.Get(r, "session")
+	pendingID, _ := session.Values["mfa_user_id"].(uint)
+	userID, loggedIn := h.GetAuthenticatedUserID(r)
+	if pendingID != 0 {
+		userID = pendingID
+	} else if !loggedIn {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := storage.CheckSecondFactor(h.DB, user, req.Code)
+	if errors.Is(err, storage.ErrBadSecondFactor) || errors.Is(err, storage.ErrTwoFactorNotActive) {
+		attempts, _ := session.Values["mfa_attempts"].(int)
+		session.Values["mfa_attempts"] = attempts + 1
+		if attempts+1 >= maxSecondFactorAttempts {
+			// Перебор кодов: пароль (или сессию) придётся предъявить заново
+			session.Options.MaxAge = -1
+		}
+		session.Save(r, w)
+		studioJSONError(w, storage.ErrBadSecondFactor.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	details := toString(session.Values["mfa_details"])
+	delete(session.Values, "mfa_user_id")
+	delete(session.Values, "mfa_details")
+	delete(session.Values, "mfa_attempts")
+	if pendingID != 0 {
+		h.logIn(w, r, user.ID, details+" + 2FA") // logIn начинает сессию с чистых значений
+	}
+	// Код только что введён: это и есть подтверждение для админских маршрутов
+	session.Values["mfa_at"] = time.Now().Unix()
+	session.Save(r, w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"redirect": h.popReturnTo(w, r)})
+}
+
+// GET /api/account/2fa
+func (h *Handler) TwoFactorStatusAPI(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.GetAuthenticatedUserID(r)
+	var user models.User
+	h.DB.Select("id, totp_enabled").First(&user, userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":             user.TOTPEnabled,
+		"recovery_codes_left": storage.RecoveryCodesLeft(h.DB, userID),
+	})
+}
+
+// POST /api/account/2fa/setup — новый секрет для приложения. Он хранится в
+// сессии, пока пользователь не подтвердит его кодом.
+func (h *Handler) SetupTwoFactorAPI(w http.ResponseWriter, r *http.Request) {
+	if !sessionOnly(w, r) {
+		return
+	}
+	userID, _ := h.GetAuthenticatedUserID(r)
+	var user models.User
+	h.DB.Select("id, email, totp_enabled").First(&user, userID)
+	if user.TOTPEnabled {
+		studioJSONError(w, storage.ErrTwoFactorEnabled.Error(), http.StatusConflict)
+		return
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		studioJSONError(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	// Сессии лежат в базе, поэтому и там секрет только зашифрованный
+	sealed, err := storage.SealTOTPSecret(user.ID, secret)
+	if err != nil {
+		studioJSONError(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	session, _ := h.Store.Get(r, "session")
+	session.Values["totp_setup"] = sealed
+	session.Save(r, w)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret": secret,
+		"url":    auth.TOTPURL(totpIssuer, user.Email, secret),
+	})
+}
+
+type twoFactorRequest struct {
+	Code string `json:"code"`
+}
+
+func (h *Handler) decodeTwoFactor(w http.ResponseWriter, r *http.Request) (models.User, string, bool) {
+	var req twoFactorRequest
+	if !sessionOnly(w, r) {
+		return models.User{}, "", false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return models.User{}, "", false
+	}
+	userID, _ := h.GetAuthenticatedUserID(r)
+	var user models.User
+	if err := h.DB.First(&user, userID).Error; err != nil {
+		studioJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return user, "", false
+	}
+	return user, req.Code, true
+}
+
+// twoFactorError answers a failed enable / disable / regenerate.
+func twoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrBadSecondFactor):
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, storage.ErrTwoFactorEnabled), errors.Is(err, storage.ErrTwoFactorNotActive):
+		studioJSONError(w, err.Error(), http.StatusConflict)
+	default:
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+	}
+}
+
+// POST /api/account/2fa/enable  {"code"} — отвечает резервными кодами
+func (h *Handler) EnableTwoFactorAPI(w http.ResponseWriter, r *http.Request) {
+	user, code, ok := h.decodeTwoFactor(w, r)
+	if !ok {
+		return
+	}
+	session, _ := h.Store.Get(r, "session")
+	sealed := toString(session.Values["totp_setup"])
+	if sealed == "" {
+		studioJSONError(w, "Start the setup first", http.StatusBadRequest)
+		return
+	}
+	codes, err := storage.EnableTwoFactor(h.DB, user.ID, sealed, code)
+	if err != nil {
+		twoFactorError(w, err)
+		return
+	}
+	delete(session.Values, "totp_setup")
+	session.Values["mfa_at"] = time.Now().Unix()
+	session.Save(r, w)
+	h.logAction(user.ID, models.LogTwoFactor, "2FA включена", 0, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// POST /api/account/2fa/disable  {"code"}
+func (h *Handler) DisableTwoFactorAPI(w http.ResponseWriter, r *http.Request) {
+	user, code, ok := h.decodeTwoFactor(w, r)
+	if !ok {
+		return
+	}
+	if err := storage.DisableTwoFactor(h.DB, user, code); err != nil {
+		twoFactorError(w, err)
+		return
+	}
+	session, _ := h.Store.Get(r, "session")
+	delete(session.Values, "mfa_at")
+	session.Save(r, w)
+	h.logAction(user.ID, models.LogTwoFactor, "2FA отключена", 0, 0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/account/2fa/recovery-codes  {"code"} — новый набор вместо старого
+func (h *Handler) RegenerateRecoveryCodesAPI(w http.ResponseWriter, r *http.Request) {
+	user, code, ok := h.decodeTwoFactor(w, r)
+	if !ok {
+		return
+	}
+	codes, err := storage.RegenerateRecoveryCodes(h.DB, user, code)
+	if err != nil {
+		twoFactorError(w, err)
+		return
+	}
+	h.logAction(user.ID, models.LogTwoFactor, "Новые резервные коды", 0, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/s/onlineCourse/internal/i18n"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+)
+
+// ─────────────────────────────────────────────
+// WEBHOOKS — подписки авторов на события своих курсов и
+// общеплатформенные подписки администратора (?scope=platform)
+// ─────────────────────────────────────────────
+
+// GET /studio/webhooks
+func (h *Handler) HandleWebhooksPage(w http.ResponseWriter, r *http.Request) {
+	roleID, userID := h.GetUserRoleID(r)
+	session, _ := h.Store.Get(r, "session")
+	lang := h.DetectLang(r)
+
+	data := PageData{
+		Title:           i18n.T(lang, "webhooks.title"),
+		IsAuthenticated: true,
+		UserID:          userID,
+		RoleID:          roleID,
+		UserName:        toString(session.Values["name"]),
+		UserPictureURL:  toString(session.Values["picture_url"]),
+		Email:           toString(session.Values["email"]),
+		CurrentPath:     r.URL.Path,
+		Lang:            lang,
+		TransJSON:       BuildTransJSON(lang),
+	}
+
+	if err := h.Tmpl.ExecuteTemplate(w, "webhooks", data); err != nil {
+		log.Printf("HandleWebhooksPage: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// webhookOwner returns the owner for a list or create request: the user
+// themselves, or nil for platform-wide webhooks, which only admins manage.
+func (h *Handler) webhookOwner(w http.ResponseWriter, r *http.Request, platform bool) (*uint, bool) {
+	roleID, userID := h.GetUserRoleID(r)
+	if !platform {
+		return &userID, true
+	}
+	if roleID < models.RoleAdmin {
+		studioJSONError(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return nil, true
+}
+
+// findWebhook loads a webhook the current user may manage.
+func (h *Handler) findWebhook(w http.ResponseWriter, r *http.Request, id uint) (models.Webhook, bool) {
+	roleID, userID := h.GetUserRoleID(r)
+	var hook models.Webhook
+	if err := h.DB.First(&hook, id).Error; err != nil {
+		studioJSONError(w, "Webhook not found", http.StatusNotFound)
+		return hook, false
+	}
+	if (hook.OwnerID == nil && roleID >= models.RoleAdmin) || (hook.OwnerID != nil && *hook.OwnerID == userID) {
+		return hook, true
+	}
+	studioJSONError(w, "Forbidden", http.StatusForbidden)
+	return hook, false
+}
+
+// GET /api/studio/webhooks?scope=platform
+func (h *Handler) ListWebhooksAPI(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.webhookOwner(w, r, r.URL.Query().Get("scope") == "platform")
+	if !ok {
+		return
+	}
+	q := h.DB.Order("created_at desc")
+	if owner == nil {
+		q = q.Where("owner_id IS NULL")
+	} else {
+		q = q.Where("owner_id = ?", *owner)
+	}
+	hooks := []models.Webhook{}
+	if err := q.Find(&hooks).Error; err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":   hooks,
+		"events": models.WebhookEvents,
+	})
+}
+
+type webhookRequest struct {
+	URL      string   `json:"url"`
+	Events   []string `json:"events"`
+	Active   *bool    `json:"active"`
+	Platform bool     `json:"platform"`
+}
+
+// POST /api/studio/webhooks  {"url": "...", "events": [...], "platform": false}
+//
+// Секрет подписи возвращается только в ответе на создание.
+func (h *Handler) CreateWebhookAPI(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	owner, ok := h.webhookOwner(w, r, req.Platform)
+	if !ok {
+		return
+	}
+
+	hook, err := storage.NewWebhook(h.DB, owner, req.URL, req.Events)
+	if errors.Is(err, storage.ErrBadWebhook) || errors.Is(err, storage.ErrWebhookAddress) {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhook": hook,
+		"secret":  hook.Secret,
+	})
+}
+
+// PUT /api/studio/webhooks/{id}  {"url": "...", "events": [...], "active": true}
+func (h *Handler) UpdateWebhookAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	hook, ok := h.findWebhook(w, r, uint(id))
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		studioJSONError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	active := hook.Active
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	err := storage.UpdateWebhook(h.DB, &hook, req.URL, req.Events, active)
+	if errors.Is(err, storage.ErrBadWebhook) || errors.Is(err, storage.ErrWebhookAddress) {
+		studioJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+// DELETE /api/studio/webhooks/{id}
+func (h *Handler) DeleteWebhookAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	hook, ok := h.findWebhook(w, r, uint(id))
+	if !ok {
+		return
+	}
+	if err := h.DB.Delete(&hook).Error; err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	h.DB.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/studio/webhooks/{id}/deliveries?page=
+func (h *Handler) ListWebhookDeliveriesAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	hook, ok := h.findWebhook(w, r, uint(id))
+	if !ok {
+		return
+	}
+	page, limit := pageParams(r)
+
+	q := h.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	var total int64
+	q.Count(&total)
+	deliveries := []models.WebhookDelivery{}
+	if err := q.Order("created_at desc").Order("id desc").
+		Limit(limit).Offset((page - 1) * limit).Find(&deliveries).Error; err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if hook.OwnerID != nil {
+		// Тела ответов в авторских подписках не показываются (записи,
+		// сохранённые до этого правила, тоже)
+		for i := range deliveries {
+			deliveries[i].ResponseBody = ""
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":  deliveries,
+		"total": total,
+		"page":  page,
+		"pages": int(math.Ceil(float64(total) / float64(limit))),
+	})
+}
+
+// POST /api/studio/webhooks/deliveries/{id}/redeliver
+func (h *Handler) RedeliverWebhookAPI(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	var original models.WebhookDelivery
+	if err := h.DB.First(&original, id).Error; err != nil {
+		studioJSONError(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := h.findWebhook(w, r, original.WebhookID); !ok {
+		return
+	}
+
+	d, err := storage.Redeliver(h.DB, original)
+	if err != nil {
+		studioJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,143 @@
+// Package jobs runs background work stored in the Postgres job queue.
+//
+// Handlers are registered by kind before Start. Jobs are enqueued with
+// storage.EnqueueJob from anywhere that has the database; recurring jobs
+// are declared with Every and enqueued once per interval across all
+// instances.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+// HandlerFunc does the work of one job. A returned error (or panic)
+// schedules a retry.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+type schedule struct {
+	kind  string
+	every time.Duration
+}
+
+// Pool is a set of workers polling the queue.
+type Pool struct {
+	DB      *gorm.DB
+	Workers int
+	// Poll is how long an idle worker waits before checking the queue again.
+	Poll time.Duration
+	// Timeout bounds a single run; jobs running longer are considered dead.
+	Timeout time.Duration
+
+	handlers  map[string]HandlerFunc
+	schedules []schedule
+}
+
+func NewPool(db *gorm.DB, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		DB:       db,
+		Workers:  workers,
+		Poll:     2 * time.Second,
+		Timeout:  5 * time.Minute,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers the handler for a job kind.
+func (p *Pool) Handle(kind string, fn HandlerFunc) {
+	p.handlers[kind] = fn
+}
+
+// Every enqueues a job of the kind once per interval. The interval slot is
+// the job's unique key, so several instances schedule it only once.
+func (p *Pool) Every(kind string, interval time.Duration) {
+	p.schedules = append(p.schedules, schedule{kind: kind, every: interval})
+}
+
+// Backoff is the delay before retrying a job after the given number of
+// attempts: 30 s, 1 min, 2 min … at most an hour.
+func Backoff(attempts int) time.Duration {
+	const max = time.Hour
+	if attempts < 1 || attempts > 8 {
+		return max
+	}
+	if d := 30 * time.Second << uint(attempts-1); d < max {
+		return d
+	}
+	return max
+}
+
+// Start launches the workers and the scheduler; they stop with ctx.
+func (p *Pool) Start(ctx context.Context) {
+	for i := 0; i < p.Workers; i++ {
+		go p.work(ctx)
+	}
+	go p.schedule(ctx)
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for {
+		job, ok, err := storage.ClaimJob(p.DB)
+		if err != nil {
+			log.Printf("jobs: claim: %v", err)
+		}
+		if ok {
+			storage.FinishJob(p.DB, &job, p.run(ctx, job), Backoff(job.Attempts))
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.Poll):
+		}
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job models.Job) (err error) {
+	fn, ok := p.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	return fn(ctx, json.RawMessage(job.Payload))
+}
+
+// schedule enqueues recurring jobs and revives jobs of dead workers.
+func (p *Pool) schedule(ctx context.Context) {
+	tick := time.NewTicker(10 * time.Second)
+	defer tick.Stop()
+	for {
+		now := time.Now()
+		for _, s := range p.schedules {
+			slot := now.Truncate(s.every)
+			storage.EnqueueJob(p.DB, s.kind, struct{}{}, storage.JobOptions{
+				RunAt:     slot,
+				UniqueKey: fmt.Sprintf("every:%s:%d", s.kind, slot.Unix()),
+			})
+		}
+		storage.RequeueStaleJobs(p.DB, p.Timeout+time.Minute)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
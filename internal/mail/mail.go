@@ -0,0 +1,162 @@
+// Package mail sends transactional e-mail over SMTP.
+//
+// For local development point SMTP_HOST/SMTP_PORT at a catcher such as
+// MailHog (localhost:1025, no auth); without SMTP_HOST messages are only
+// logged.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is one outgoing e-mail.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string // например List-Unsubscribe
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Config holds SMTP settings.
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// ConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD and
+// SMTP_FROM.
+func ConfigFromEnv() Config {
+	c := Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if c.Port == "" {
+		c.Port = "587"
+	}
+	if c.From == "" {
+		c.From = "CoursePlatform <no-reply@localhost>"
+	}
+	return c
+}
+
+// NewSender returns an SMTP sender, or a logging one when no host is set.
+func NewSender(c Config) Sender {
+	if c.Host == "" {
+		log.Println("Warning: SMTP_HOST not set, e-mails will only be logged.")
+		return LogSender{}
+	}
+	return &SMTPSender{Config: c}
+}
+
+// LogSender writes messages to the log instead of sending them.
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Printf("mail (not sent): to=%s subject=%q", msg.To, msg.Subject)
+	return nil
+}
+
+// SMTPSender sends messages through an SMTP server. STARTTLS is used when
+// the server offers it; auth only when a username is configured.
+type SMTPSender struct {
+	Config Config
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	from, err := mail.ParseAddress(s.Config.From)
+	if err != nil {
+		return fmt.Errorf("SMTP_FROM: %w", err)
+	}
+	body, err := build(s.Config.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Config.Username != "" {
+		auth = smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)
+	}
+	addr := s.Config.Host + ":" + s.Config.Port
+	return smtp.SendMail(addr, auth, from.Address, []string{msg.To}, body)
+}
+
+// build renders a multipart/alternative message with text and HTML parts.
+func build(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers := map[string]string{
+		"From":         from,
+		"To":           msg.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-ID":   messageID(from),
+		"MIME-Version": "1.0",
+		"Content-Type": `multipart/alternative; boundary="` + mw.Boundary() + `"`,
+	}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	var head bytes.Buffer
+	for k, v := range headers {
+		fmt.Fprintf(&head, "%s: %s\r\n", k, v)
+	}
+	head.WriteString("\r\n")
+
+	for _, part := range []struct{ ctype, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.body == "" {
+			continue
+		}
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ctype},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		w.Write([]byte(strings.ReplaceAll(part.body, "\n", "\r\n")))
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,95 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+// Notifier e-mails users about the notifications they opted into.
+type Notifier struct {
+	DB      *gorm.DB
+	Sender  Sender
+	BaseURL string
+}
+
+// letterKind maps a notification to an e-mail template and preference
+// category; notifications without a letter return "".
+func letterKind(n models.Notification) (kind, category string) {
+	switch n.Kind {
+	case models.NotifyEnrollment:
+		if n.Detail == models.EnrollmentApproved || n.Detail == models.EnrollmentRejected {
+			return "enrollment_" + n.Detail, models.EmailEnrollment
+		}
+	case models.NotifyCourseApproved, models.NotifyCourseRejected:
+		return n.Kind, models.EmailCourseReview
+	case models.NotifyCertificate:
+		return n.Kind, models.EmailCertificate
+	}
+	return "", ""
+}
+
+// HandleNotification is registered with storage.OnNotify; letters are
+// sent by the job queue, which retries SMTP failures.
+func (m *Notifier) HandleNotification(n models.Notification) {
+	if kind, _ := letterKind(n); kind != "" {
+		storage.EnqueueJob(m.DB, models.JobNotificationEmail, notificationJob{NotificationID: n.ID}, storage.JobOptions{})
+	}
+}
+
+type notificationJob struct {
+	NotificationID uint `json:"notification_id"`
+}
+
+// RunJob sends the letter for a models.JobNotificationEmail job.
+func (m *Notifier) RunJob(ctx context.Context, payload json.RawMessage) error {
+	var job notificationJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	var n models.Notification
+	if err := m.DB.First(&n, job.NotificationID).Error; err != nil {
+		return nil // уведомление удалено — письмо больше не нужно
+	}
+	kind, category := letterKind(n)
+	if kind == "" {
+		return nil
+	}
+
+	var user models.User
+	if err := m.DB.First(&user, n.UserID).Error; err != nil || user.Email == "" {
+		return nil
+	}
+	prefs, err := storage.EmailPreferences(m.DB, user.ID)
+	if err != nil {
+		return fmt.Errorf("mail %s: preferences: %w", kind, err)
+	}
+	if !prefs.Allows(category) {
+		return nil
+	}
+
+	note := ""
+	if kind == models.NotifyCourseRejected {
+		note = n.Detail
+	}
+	msg, err := Render(Letter{
+		Kind:           kind,
+		Lang:           user.Language,
+		Vars:           map[string]string{"name": user.Name, "subject": n.Subject},
+		Note:           note,
+		ActionURL:      m.BaseURL + n.Link,
+		UnsubscribeURL: m.BaseURL + "/email/unsubscribe/" + prefs.Token + "?c=" + category,
+	})
+	if err != nil {
+		return fmt.Errorf("mail %s: render: %w", kind, err)
+	}
+	msg.To = user.Email
+	if err := m.Sender.Send(msg); err != nil {
+		return fmt.Errorf("mail %s to %s: %w", kind, user.Email, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package mail
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/s/onlineCourse/internal/i18n"
+)
+
+// Letter is the content of a templated e-mail. Text fields are i18n keys
+// suffixes under "email.<Kind>."; Vars fill {placeholders} in them.
+type Letter struct {
+	Kind           string // enrollment_approved, course_rejected, …
+	Lang           string
+	Vars           map[string]string
+	Note           string // цитата: причина отказа и т.п.
+	ActionURL      string
+	UnsubscribeURL string
+}
+
+var layout = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<body style="margin:0;padding:24px;background:#f1f5f9;font-family:Arial,Helvetica,sans-serif;color:#0f172a">
+<div style="max-width:560px;margin:0 auto;background:#ffffff;border-radius:12px;padding:32px">
+  <p style="font-size:16px;margin:0 0 16px">{{.Greeting}}</p>
+  <p style="font-size:15px;line-height:1.6;margin:0 0 16px">{{.Body}}</p>
+  {{if .Note}}<blockquote style="margin:0 0 16px;padding:12px 16px;background:#f8fafc;border-left:4px solid #6366f1;font-size:14px;color:#475569">{{.Note}}</blockquote>{{end}}
+  {{if .ActionURL}}<p style="margin:24px 0"><a href="{{.ActionURL}}" style="display:inline-block;background:#4f46e5;color:#ffffff;text-decoration:none;padding:12px 24px;border-radius:8px;font-weight:bold">{{.Action}}</a></p>{{end}}
+  <hr style="border:none;border-top:1px solid #e2e8f0;margin:24px 0">
+  <p style="font-size:12px;color:#94a3b8;margin:0">{{.Footer}}{{if .UnsubscribeURL}} <a href="{{.UnsubscribeURL}}" style="color:#94a3b8">{{.Unsubscribe}}</a>{{end}}</p>
+</div>
+</body>
+</html>`))
+
+// Render builds a localized message for the letter (recipient not set).
+func Render(l Letter) (Message, error) {
+	tr := func(key string) string {
+		s := i18n.T(l.Lang, key)
+		for k, v := range l.Vars {
+			s = strings.ReplaceAll(s, "{"+k+"}", v)
+		}
+		return s
+	}
+	data := map[string]string{
+		"Lang":           l.Lang,
+		"Greeting":       tr("email.greeting"),
+		"Body":           tr("email." + l.Kind + ".body"),
+		"Action":         tr("email." + l.Kind + ".action"),
+		"Note":           l.Note,
+		"ActionURL":      l.ActionURL,
+		"Footer":         tr("email.footer"),
+		"Unsubscribe":    tr("email.unsubscribe"),
+		"UnsubscribeURL": l.UnsubscribeURL,
+	}
+
+	var html bytes.Buffer
+	if err := layout.Execute(&html, data); err != nil {
+		return Message{}, err
+	}
+
+	var text strings.Builder
+	text.WriteString(data["Greeting"] + "\n\n" + data["Body"] + "\n")
+	if l.Note != "" {
+		text.WriteString("\n> " + l.Note + "\n")
+	}
+	if l.ActionURL != "" {
+		text.WriteString("\n" + data["Action"] + ": " + l.ActionURL + "\n")
+	}
+	text.WriteString("\n--\n" + data["Footer"] + "\n")
+	if l.UnsubscribeURL != "" {
+		text.WriteString(data["Unsubscribe"] + ": " + l.UnsubscribeURL + "\n")
+	}
+
+	msg := Message{
+		Subject: tr("email." + l.Kind + ".subject"),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}
+	if l.UnsubscribeURL != "" {
+		// RFC 8058: почтовые клиенты показывают кнопку «Отписаться»
+		msg.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + l.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/s/onlineCourse/internal/handlers"
+)
+
+// APITokens принимает персональные токены (Authorization: Bearer) в /api/
+// и проверяет, что их права покрывают запрос. Роль пользователя дальше
+// проверяет RequiredRole, как и для сессии.
+func APITokens(h *handlers.Handler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r, err := h.WithAPIToken(r)
+			if err != nil || !strings.HasPrefix(r.URL.Path, "/api/") {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				jsonError(w, "invalid or revoked API token", http.StatusUnauthorized)
+				return
+			}
+			if token, _ := handlers.APITokenFrom(r); !handlers.APITokenAllows(token, r) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+				jsonError(w, "insufficient_scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func jsonError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -24,6 +24,13 @@ func RequiredRole(h *handlers.Handler, requiredRoleID uint) func(next http.Handl
 				return
 			}
 
+			// Админские маршруты по API-токену — только с правом admin
+			token, viaToken := handlers.APITokenFrom(r)
+			if viaToken && requiredRoleID >= models.RoleAdmin && !token.HasScope(models.ScopeAdmin) {
+				jsonError(w, "insufficient_scope", http.StatusForbidden)
+				return
+			}
+
 			// 2. Получение данных пользователя для проверки Роли
 			var user models.User
 			if err := h.DB.First(&user, userID).Error; err != nil {
@@ -38,7 +45,14 @@ func RequiredRole(h *handlers.Handler, requiredRoleID uint) func(next http.Handl
 				return
 			}
 
-			// 4. Если все проверки пройдены, вызываем следующий обработчик
+			// 4. Админские маршруты — только после недавнего ввода кода 2FA (step-up).
+			// API-токен с правом admin выдаётся лишь после такой проверки.
+			if requiredRoleID >= models.RoleAdmin && !viaToken && !h.SteppedUp(r) {
+				h.RequireStepUp(w, r, user)
+				return
+			}
+
+			// 5. Если все проверки пройдены, вызываем следующий обработчик
 			next.ServeHTTP(w, r)
 		}
 	}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/s/onlineCourse/internal/handlers"
+)
+
+// CSRF проверяет токен сессии во всех POST/PUT/DELETE запросах к /api/.
+// Страницы вне /api/ (выход, отписка по ссылке из письма) не проверяются.
+func CSRF(h *handlers.Handler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, viaToken := handlers.APITokenFrom(r) // без cookie подделать запрос нечем
+			if viaToken || strings.HasPrefix(r.URL.Path, "/static/") || strings.HasPrefix(r.URL.Path, "/uploads/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !h.ValidCSRF(w, r) && strings.HasPrefix(r.URL.Path, "/api/") {
+				handlers.CSRFFailed(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
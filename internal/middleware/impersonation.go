@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/s/onlineCourse/internal/handlers"
+)
+
+// Impersonation оставляет админу в режиме «просмотр от имени» только чтение:
+// изменить данные ученика, войти или привязать аккаунт нельзя. Разрешены
+// выход и завершение режима.
+func Impersonation(h *handlers.Handler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := h.Impersonator(r); !ok || impersonationAllows(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				jsonError(w, "impersonation_read_only", http.StatusForbidden)
+				return
+			}
+			h.HandleForbiddenPage(w, r)
+		})
+	}
+}
+
+var sideEffectGETs = []string{"/auth/"}
+
+func impersonationAllows(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/api/impersonation/stop", "/logout":
+		return true
+	}
+	// GET-запросы, которые всё же меняют данные: /auth/... (колбэки
+	// провайдеров) входит или привязывает аккаунт
+	for _, prefix := range sideEffectGETs {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Права персональных API-токенов
+const (
+	ScopeCoursesRead = "courses:read" // чтение курсов, уроков и каталога
+	ScopeStudioWrite = "studio:write" // /api/studio/ (свои курсы)
+	ScopeAdmin       = "admin"        // всё API, кроме /api/account/
+)
+
+// APIScopes lists the scopes in the order shown in the cabinet.
+var APIScopes = []string{ScopeCoursesRead, ScopeStudioWrite, ScopeAdmin}
+
+// APIToken — персональный токен для скриптов: Authorization: Bearer <token>.
+// Сам токен показывается один раз при создании, в базе — его SHA-256.
+type APIToken struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:12;not null" json:"prefix"` // начало токена, чтобы узнать его в списке
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Scopes     string     `gorm:"size:100;not null" json:"scopes"` // через пробел
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:45" json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope reports whether the token grants scope. Admin grants everything,
+// studio:write also lets read.
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range strings.Fields(t.Scopes) {
+		if s == scope || s == ScopeAdmin || (s == ScopeStudioWrite && scope == ScopeCoursesRead) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ApplicationField — поле анкеты, которую ученик заполняет при подаче заявки
+// на закрытый курс. Настраивается автором в студии.
+type ApplicationField struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+
+	CourseID uint           `gorm:"index;not null" json:"course_id"`
+	Order    int            `json:"order"`
+	Type     string         `gorm:"size:20;not null" json:"type"` // "text", "textarea", "choice", "multichoice", "file"
+	Label    string         `json:"label"`
+	Help     string         `json:"help"`
+	Required bool           `json:"required"`
+	Options  datatypes.JSON `json:"options"` // ["вариант 1", ...] для choice/multichoice
+}
+
+// EnrollmentAnswer — ответ на поле анкеты. Подпись поля копируется, чтобы
+// ответ оставался понятным после изменения анкеты.
+type EnrollmentAnswer struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	EnrollmentID uint           `gorm:"index;not null" json:"enrollment_id"`
+	FieldID      uint           `json:"field_id"`
+	Type         string         `json:"type"`
+	Label        string         `json:"label"`
+	Value        datatypes.JSON `json:"value"` // строка или массив строк (multichoice); для file — URL
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Назначение одноразовых ссылок из писем
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenPasswordReset = "password_reset"
+	TokenMagicLink     = "magic_link"
+)
+
+// AuthToken — одноразовая ссылка из письма: подтверждение адреса, сброс
+// пароля или вход без пароля. В базе лежит только SHA-256 токена, сам
+// токен есть лишь в письме.
+type AuthToken struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Purpose   string    `gorm:"size:20;not null"`
+	Email     string    `gorm:"size:255;index;not null"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// RecoveryCode — одноразовый код для входа без телефона с аутентификатором.
+// Выдаётся набором при включении 2FA, хранится только SHA-256.
+type RecoveryCode struct {
+	ID       uint   `gorm:"primarykey"`
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"size:64;not null"`
+	UsedAt   *time.Time
+}
@@ -12,7 +12,63 @@ type Certificate struct {
 	CourseID uint      `gorm:"uniqueIndex:idx_user_course_cert;index" json:"course_id"`
 	Code     string    `gorm:"uniqueIndex;size:64" json:"code"` // уникальный хэш для верификации
 	IssuedAt time.Time `json:"issued_at"`
+	Grade    *int      `json:"grade"`            // % верных ответов в тестах курса; nil — в курсе нет тестов
+	Signed   string    `json:"signed,omitempty"` // JWS (EdDSA) с данными сертификата
+	KeyID    string    `gorm:"size:32" json:"kid,omitempty"`
 
-	User   User   `json:"user" gorm:"foreignKey:UserID"`
-	Course Course `json:"course" gorm:"foreignKey:CourseID"`
+	RevokedAt    *time.Time `json:"revoked_at"` // nil — сертификат действителен
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+
+	User    User               `json:"user" gorm:"foreignKey:UserID"`
+	Course  Course             `json:"course" gorm:"foreignKey:CourseID"`
+	History []CertificateEvent `json:"history,omitempty" gorm:"foreignKey:CertificateID"`
+}
+
+// Действия в истории сертификата
+const (
+	CertificateRevoked  = "revoked"
+	CertificateReissued = "reissued"
+)
+
+// Revoked reports whether the certificate has been withdrawn.
+func (c Certificate) Revoked() bool { return c.RevokedAt != nil }
+
+// CertificateEvent — журнал отзывов и перевыпусков сертификата (только добавление).
+type CertificateEvent struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	CertificateID uint      `gorm:"index;not null" json:"certificate_id"`
+	Action        string    `gorm:"size:20" json:"action"` // см. константы Certificate*
+	ActorID       uint      `json:"actor_id"`
+	Reason        string    `json:"reason"`
+
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+// CertificateTemplate — оформление PDF-сертификата курса. Настраивается
+// автором; если записи нет, используется DefaultCertificateTemplate.
+type CertificateTemplate struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CourseID    uint   `gorm:"uniqueIndex;not null" json:"course_id"`
+	Layout      string `gorm:"size:20;default:'classic'" json:"layout"` // "classic", "modern", "minimal"
+	AccentColor string `gorm:"size:7;default:'#4f46e5'" json:"accent_color"`
+	Heading     string `json:"heading"` // пусто — стандартный заголовок на языке ученика
+	Body        string `json:"body"`    // текст над названием курса; пусто — стандартный
+	SignerName  string `json:"signer_name"`
+	SignerTitle string `json:"signer_title"`
+	ShowGrade   bool   `json:"show_grade"`
+}
+
+// DefaultCertificateTemplate returns the template used for courses without
+// their own settings.
+func DefaultCertificateTemplate(courseID uint) CertificateTemplate {
+	return CertificateTemplate{
+		CourseID:    courseID,
+		Layout:      "classic",
+		AccentColor: "#4f46e5",
+		ShowGrade:   true,
+	}
 }
@@ -26,6 +26,15 @@ type Course struct {
 	AdminStatus string `json:"admin_status" gorm:"default:'approved'"`
 	ReviewNote  string `json:"review_note"`
 
+	// Enrollment rules for closed courses
+	AutoApprove        string `json:"auto_approve" gorm:"default:'none'"` // none | all | domain
+	AutoApproveDomains string `json:"auto_approve_domains"`               // "school.kg, corp.com" — used when AutoApprove = domain
+	MaxSeats           int    `json:"max_seats"`                          // 0 → без ограничения
+	AccessDays         int    `json:"access_days"`                        // 0 → доступ бессрочный
+
+	// Отзыв можно оставить, пройдя не меньше этой доли уроков (%); 0 → любой ученик
+	ReviewMinProgress int `json:"review_min_progress"`
+
 	Author  User     `json:"author" gorm:"foreignKey:AuthorID"`
 	Modules []Module `json:"modules" gorm:"constraint:OnDelete:CASCADE;"`
 }
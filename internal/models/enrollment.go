@@ -6,16 +6,43 @@ import (
 	"gorm.io/gorm"
 )
 
+// Статусы заявки на курс
+const (
+	EnrollmentPending    = "pending"
+	EnrollmentApproved   = "approved"
+	EnrollmentRejected   = "rejected"
+	EnrollmentWaitlisted = "waitlisted" // допущен, ждёт освобождения места
+	EnrollmentExpired    = "expired"    // срок доступа истёк
+	EnrollmentUnenrolled = "unenrolled" // ученик покинул курс сам
+)
+
 // Enrollment (Заявка на курс / Подписка)
 type Enrollment struct {
 	gorm.Model
-	UserID   uint   `json:"user_id"`
-	CourseID uint   `json:"course_id"`
-	Status   string `json:"status"` // pending, approved, rejected
+	UserID    uint       `json:"user_id"`
+	CourseID  uint       `json:"course_id"`
+	Status    string     `json:"status"`     // см. константы Enrollment*
+	ExpiresAt *time.Time `json:"expires_at"` // nil → бессрочно
+	Cohort    string     `json:"cohort"`     // группа (поток), например из приглашения
 
 	// Убираем json:"-" чтобы видеть данные в API
-	User   User   `json:"user" gorm:"foreignKey:UserID"`
-	Course Course `json:"course" gorm:"foreignKey:CourseID"`
+	User    User                `json:"user" gorm:"foreignKey:UserID"`
+	Course  Course              `json:"course" gorm:"foreignKey:CourseID"`
+	History []EnrollmentHistory `json:"history,omitempty" gorm:"foreignKey:EnrollmentID"`
+	Answers []EnrollmentAnswer  `json:"answers,omitempty" gorm:"foreignKey:EnrollmentID"`
+}
+
+// EnrollmentHistory — журнал смены статусов заявки (только добавление).
+type EnrollmentHistory struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	EnrollmentID uint      `gorm:"index;not null" json:"enrollment_id"`
+	FromStatus   string    `json:"from_status"` // "" для новой заявки
+	ToStatus     string    `json:"to_status"`
+	ActorID      uint      `json:"actor_id"` // 0 → система (лист ожидания, истечение срока)
+	Reason       string    `json:"reason"`
+
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
 }
 
 // models/progress.go
@@ -13,14 +13,51 @@ type Comment struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	UserID   uint   `json:"user_id"`
-	LessonID uint   `json:"lesson_id"` // >0 → комментарий к уроку
-	CourseID uint   `json:"course_id"` // >0 → комментарий к курсу
-	Content  string `json:"content"`
+	UserID   uint       `json:"user_id"`
+	LessonID uint       `json:"lesson_id"` // >0 → комментарий к уроку
+	CourseID uint       `json:"course_id"` // >0 → комментарий к курсу
+	Content  string     `json:"content"`
+	EditedAt *time.Time `json:"edited_at"` // nil — не редактировался
+
+	// см. константы Content*; в публичной выдаче только "published"
+	Status string `gorm:"size:20;default:'published';index" json:"status"`
+
+	// Ветки обсуждения: ответ хранит родителя и корень ветки,
+	// чтобы ветку целиком можно было загрузить одним запросом.
+	ParentID *uint `gorm:"index" json:"parent_id"`
+	RootID   *uint `gorm:"index" json:"root_id"`
+	Depth    int   `json:"depth"` // 0 — комментарий верхнего уровня
+	// Автору родителя ещё не сообщили об ответе: уведомление уходит, когда
+	// ответ опубликован (сразу или после проверки модератором)
+	NotifyReply bool `json:"-"`
+
+	User     User             `json:"user" gorm:"foreignKey:UserID"`
+	Mentions []CommentMention `json:"mentions,omitempty" gorm:"foreignKey:CommentID"`
+
+	// Заполняются при выдаче ветки, в БД не хранятся
+	Replies    []Comment `gorm:"-" json:"replies,omitempty"`
+	ReplyCount int       `gorm:"-" json:"reply_count"`
+	Deleted    bool      `gorm:"-" json:"deleted,omitempty"` // удалён, но на него есть ответы
+}
+
+// CommentMention — упоминание пользователя в комментарии (@public_id или
+// @Имя_Фамилия). Handle хранит текст упоминания как он написан, чтобы
+// клиент мог заменить его ссылкой на профиль.
+type CommentMention struct {
+	ID        uint   `gorm:"primarykey" json:"-"`
+	CommentID uint   `gorm:"uniqueIndex:idx_comment_mention;not null" json:"-"`
+	UserID    uint   `gorm:"uniqueIndex:idx_comment_mention;not null" json:"user_id"`
+	Handle    string `gorm:"size:100" json:"handle"`
+	// Упомянутому ещё не сообщили: комментарий ждёт проверки
+	NotifyPending bool `json:"-"`
 
 	User User `json:"user" gorm:"foreignKey:UserID"`
 }
 
+// MaxCommentDepth limits nesting: replies deeper than this are attached to
+// the parent's parent instead.
+const MaxCommentDepth = 3
+
 // Review - Отзыв к курсу
 type Review struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -32,7 +69,24 @@ type Review struct {
 	CourseID uint   `json:"course_id"`
 	Rating   int    `json:"rating"` // 1-5
 	Content  string `json:"content"`
+	Status   string `gorm:"size:20;default:'published';index" json:"status"` // см. константы Content*
+
+	// Публичный ответ автора курса (один на отзыв, можно править)
+	Response    string     `json:"response"`
+	RespondedAt *time.Time `json:"responded_at"`
+
+	HelpfulCount int `gorm:"default:0" json:"helpful_count"`
 
 	User   User   `json:"user" gorm:"foreignKey:UserID"`
 	Course Course `json:"course" gorm:"foreignKey:CourseID"` // <--- Добавлено
+
+	Helpful bool `gorm:"-" json:"helpful"` // текущий пользователь отметил «полезно»
+}
+
+// ReviewVote — отметка «полезный отзыв»; повторная отметка снимает её.
+type ReviewVote struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"uniqueIndex:idx_review_vote;not null" json:"user_id"`
+	ReviewID  uint      `gorm:"uniqueIndex:idx_review_vote;not null" json:"review_id"`
 }
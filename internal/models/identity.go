@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external OpenID Connect
+// provider. One user may have several: Google and a corporate SSO.
+type UserIdentity struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"index;not null"`
+	Provider  string `gorm:"size:40;not null;uniqueIndex:idx_identity_subject,priority:1"`
+	Subject   string `gorm:"size:255;not null;uniqueIndex:idx_identity_subject,priority:2"` // claim "sub"
+	Email     string `gorm:"size:255"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PendingInvitation — приглашение на курс для email, который ещё не
+// зарегистрирован. Забирается при первом входе через storage.SaveUser.
+type PendingInvitation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Email       string     `gorm:"uniqueIndex:idx_invitation_email_course;size:255;not null" json:"email"`
+	CourseID    uint       `gorm:"uniqueIndex:idx_invitation_email_course;not null" json:"course_id"`
+	Name        string     `json:"name"`
+	Cohort      string     `gorm:"size:100" json:"cohort"`
+	InvitedByID uint       `json:"invited_by_id"`
+	ClaimedAt   *time.Time `json:"claimed_at"`
+	ClaimedByID uint       `json:"claimed_by_id"`
+}
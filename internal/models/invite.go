@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// CourseInvite — приглашение на закрытый курс: ссылка или код доступа.
+// Погашение сразу создаёт одобренную запись на курс.
+type CourseInvite struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CourseID    uint       `gorm:"index;not null" json:"course_id"`
+	CreatedByID uint       `json:"created_by_id"`
+	Kind        string     `gorm:"size:10;not null" json:"kind"`              // "link" | "code"
+	Token       string     `gorm:"uniqueIndex;size:64;not null" json:"token"` // токен ссылки или код доступа
+	MaxUses     int        `json:"max_uses"`                                  // 0 → без ограничения
+	UsedCount   int        `json:"used_count"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Cohort      string     `gorm:"size:100" json:"cohort"` // группа, в которую попадает ученик
+	RevokedAt   *time.Time `json:"revoked_at"`
+
+	Course Course `json:"-" gorm:"foreignKey:CourseID"`
+}
+
+// InviteRedemption — кто и когда воспользовался приглашением.
+type InviteRedemption struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	InviteID     uint      `gorm:"uniqueIndex:idx_invite_user;not null" json:"invite_id"`
+	UserID       uint      `gorm:"uniqueIndex:idx_invite_user;not null" json:"user_id"`
+	EnrollmentID uint      `json:"enrollment_id"`
+
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
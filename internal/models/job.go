@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Виды фоновых задач
+const (
+	JobIssueCertificate  = "certificate.issue"  // проверить прогресс и выдать сертификат
+	JobNotificationEmail = "email.notification" // письмо по уведомлению
+	JobDeliverWebhooks   = "webhooks.deliver"   // отправить ждущие вебхуки (после события и по расписанию)
+	JobExpireEnrollments = "enrollments.expire" // закрыть истёкший доступ (по расписанию)
+	JobCleanupJobs       = "jobs.cleanup"       // удалить старые выполненные задачи (по расписанию)
+	JobCleanupSessions   = "sessions.cleanup"   // удалить истёкшие сессии (по расписанию)
+)
+
+// Статусы задачи
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed" // попытки исчерпаны, ждёт ручного повтора
+)
+
+// Job — задача очереди. Воркеры забирают готовые к запуску задачи через
+// SELECT … FOR UPDATE SKIP LOCKED, поэтому несколько инстансов не выполнят
+// одну задачу дважды. UniqueKey не даёт поставить задачу по расписанию
+// повторно за один и тот же интервал.
+type Job struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Kind        string         `gorm:"size:60;index;not null" json:"kind"`
+	Payload     datatypes.JSON `json:"payload"`
+	UniqueKey   *string        `gorm:"uniqueIndex;size:120" json:"unique_key"`
+	Status      string         `gorm:"size:20;index:idx_jobs_ready,priority:1;not null" json:"status"`
+	RunAt       time.Time      `gorm:"index:idx_jobs_ready,priority:2" json:"run_at"`
+	Attempts    int            `json:"attempts"`
+	MaxAttempts int            `json:"max_attempts"`
+	LockedAt    *time.Time     `json:"locked_at"`
+	LastError   string         `gorm:"type:text" json:"last_error"`
+	FinishedAt  *time.Time     `json:"finished_at"`
+}
@@ -12,6 +12,20 @@ const (
 	LogReviewAdded     = "review_added"
 	LogCommentAdded    = "comment_added"
 	LogReactionAdded   = "reaction_added"
+	LogInviteRedeemed  = "invite_redeemed"
+	LogBulkEnrollment  = "bulk_enrollment"
+	LogUnenrolled      = "unenrolled"
+	LogSigningKey      = "signing_key" // ротация или отзыв ключа подписи сертификатов
+	LogCertRevoked     = "certificate_revoked"
+	LogCertReissued    = "certificate_reissued"
+	LogQuestionAsked   = "question_asked"
+	LogAnswerAdded     = "answer_added"
+	LogModeration      = "moderation" // скрытие, восстановление, удаление, ограничения
+	LogJobRetry        = "job_retry"  // ручной повтор упавшей фоновой задачи
+	LogSessionRevoked  = "session_revoked" // выход на другом устройстве или везде
+	LogAPIToken        = "api_token"       // создание или отзыв персонального API-токена
+	LogTwoFactor       = "two_factor"      // включение и отключение 2FA, новые резервные коды
+	LogImpersonation   = "impersonation"   // админ начал или закончил просмотр от имени пользователя
 )
 
 // UserLog хранит историю действий пользователя
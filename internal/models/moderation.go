@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Статусы публикации комментариев и отзывов
+const (
+	ContentPublished = "published"
+	ContentPending   = "pending" // ждёт проверки: сработал фильтр запрещённых слов
+	ContentHidden    = "hidden"  // скрыт модератором
+)
+
+// Статусы жалоб
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"  // модератор скрыл или удалил материал
+	ReportDismissed = "dismissed" // материал восстановлен, жалоба отклонена
+)
+
+// Report — жалоба пользователя на комментарий или отзыв
+type Report struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	TargetType   string     `gorm:"uniqueIndex:idx_report;size:10;not null" json:"target_type"` // "comment" | "review"
+	TargetID     uint       `gorm:"uniqueIndex:idx_report;not null" json:"target_id"`
+	ReporterID   uint       `gorm:"uniqueIndex:idx_report;not null" json:"reporter_id"`
+	Reason       string     `json:"reason"`
+	Status       string     `gorm:"size:20;default:'open';index" json:"status"`
+	ResolvedByID uint       `json:"resolved_by_id"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+
+	Reporter User `json:"reporter" gorm:"foreignKey:ReporterID"`
+}
+
+// BannedWord — запрещённое слово для пре-модерации. Слово с «*» на конце
+// совпадает с любым словом, начинающимся так же.
+type BannedWord struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Lang      string    `gorm:"uniqueIndex:idx_banned_word;size:5;not null" json:"lang"`
+	Word      string    `gorm:"uniqueIndex:idx_banned_word;size:100;not null" json:"word"`
+}
+
+// Виды ограничений
+const (
+	RestrictionMute = "mute" // временный запрет писать
+	RestrictionBan  = "ban"  // бессрочный запрет
+)
+
+// UserRestriction — запрет пользователю писать комментарии, отзывы и вопросы
+type UserRestriction struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	Kind      string     `gorm:"size:10;not null" json:"kind"` // см. константы Restriction*
+	Until     *time.Time `json:"until"`                        // nil — бессрочно
+	Reason    string     `json:"reason"`
+	ByID      uint       `json:"by_id"`
+
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
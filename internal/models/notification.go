@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Виды уведомлений
+const (
+	NotifyMention        = "mention"            // упоминание в комментарии
+	NotifyCommentReply   = "comment_reply"      // ответ на комментарий
+	NotifyEnrollment     = "enrollment_status"  // заявка на курс сменила статус
+	NotifyCourseApproved = "course_approved"    // курс прошёл проверку администратора
+	NotifyCourseRejected = "course_rejected"    // курс отклонён администратором
+	NotifyCertificate    = "certificate_issued" // выдан сертификат
+)
+
+// Notification — уведомление пользователю о событии на платформе.
+type Notification struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID  uint       `gorm:"index;not null" json:"user_id"` // получатель
+	ActorID uint       `json:"actor_id"`                      // 0 — системное
+	Kind    string     `gorm:"size:40;not null" json:"kind"`  // см. константы Notify*
+	Subject string     `json:"subject"`                       // название курса или урока
+	Detail  string     `json:"detail"`                        // новый статус, причина отказа, начало ответа
+	Link    string     `json:"link"`
+	ReadAt  *time.Time `gorm:"index" json:"read_at"`
+
+	Actor User `json:"actor" gorm:"foreignKey:ActorID"`
+}
+
+// Категории писем, от которых можно отписаться
+const (
+	EmailEnrollment   = "enrollment"    // решения по заявкам на курсы
+	EmailCourseReview = "course_review" // итоги проверки курса администратором
+	EmailCertificate  = "certificate"   // выдача сертификатов
+)
+
+// EmailPreference — какие письма получает пользователь. Флаги Mute* хранят
+// отказ, так что по умолчанию письма приходят. Token подписывает ссылки
+// «отписаться» — по ним можно отписаться без входа на сайт.
+type EmailPreference struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	UserID    uint      `gorm:"uniqueIndex;not null" json:"-"`
+	Token     string    `gorm:"uniqueIndex;size:64;not null" json:"-"`
+
+	MuteEnrollment   bool `json:"-"`
+	MuteCourseReview bool `json:"-"`
+	MuteCertificate  bool `json:"-"`
+}
+
+// Allows reports whether letters of the category may be sent.
+func (p EmailPreference) Allows(category string) bool {
+	switch category {
+	case EmailEnrollment:
+		return !p.MuteEnrollment
+	case EmailCourseReview:
+		return !p.MuteCourseReview
+	case EmailCertificate:
+		return !p.MuteCertificate
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Question — вопрос ученика к уроку (режим «Вопросы и ответы»)
+type Question struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	CourseID uint   `gorm:"index;not null" json:"course_id"`
+	LessonID uint   `gorm:"index;not null" json:"lesson_id"`
+	UserID   uint   `gorm:"index" json:"user_id"`
+	Title    string `gorm:"size:200" json:"title"`
+	Body     string `json:"body"`
+
+	AcceptedAnswerID *uint `json:"accepted_answer_id"` // выбирает автор курса или ассистент
+	Upvotes          int   `gorm:"default:0" json:"upvotes"`
+	AnswerCount      int   `gorm:"default:0" json:"answer_count"`
+
+	User    User     `json:"user" gorm:"foreignKey:UserID"`
+	Lesson  *Lesson  `json:"lesson,omitempty" gorm:"foreignKey:LessonID"`
+	Answers []Answer `json:"answers,omitempty" gorm:"foreignKey:QuestionID"`
+
+	Voted bool `gorm:"-" json:"voted"` // текущий пользователь проголосовал
+}
+
+// Answer — ответ на вопрос
+type Answer struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	QuestionID uint   `gorm:"index;not null" json:"question_id"`
+	UserID     uint   `gorm:"index" json:"user_id"`
+	Body       string `json:"body"`
+	Upvotes    int    `gorm:"default:0" json:"upvotes"`
+
+	User User `json:"user" gorm:"foreignKey:UserID"`
+
+	Voted    bool `gorm:"-" json:"voted"`
+	Accepted bool `gorm:"-" json:"accepted"`
+	ByStaff  bool `gorm:"-" json:"by_staff"` // ответил автор курса или ассистент
+}
+
+// QAVote — голос «полезно» за вопрос или ответ; повторный голос снимает его.
+type QAVote struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UserID     uint      `gorm:"uniqueIndex:idx_qa_vote;not null" json:"user_id"`
+	TargetType string    `gorm:"uniqueIndex:idx_qa_vote;size:10;not null" json:"target_type"` // "question" | "answer"
+	TargetID   uint      `gorm:"uniqueIndex:idx_qa_vote;not null" json:"target_id"`
+}
+
+// CourseAssistant — ассистент (TA) курса: отвечает на вопросы и отмечает
+// принятые ответы наравне с автором.
+type CourseAssistant struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	CourseID  uint      `gorm:"uniqueIndex:idx_course_assistant;not null" json:"course_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_course_assistant;not null" json:"user_id"`
+
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session — сессия браузера на сервере. В cookie лежит только подписанный
+// случайный токен, в базе — его SHA-256, поэтому сессию можно отозвать:
+// выйти на одном устройстве или везде сразу.
+type Session struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	TokenHash  string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	UserID     uint      `gorm:"index" json:"-"` // 0 — гость (return_to, nonce входа)
+	Data       []byte    `json:"-"`              // значения sessions.Session в gob
+	UserAgent  string    `gorm:"size:255" json:"user_agent"`
+	IP         string    `gorm:"size:45" json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `gorm:"index;not null" json:"expires_at"`
+
+	Current bool `gorm:"-" json:"current"` // сессия этого запроса
+}
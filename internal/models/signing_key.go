@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Состояния ключа подписи сертификатов
+const (
+	SigningKeyActive  = "active"  // подписывает новые сертификаты
+	SigningKeyRetired = "retired" // больше не подписывает, старые подписи действительны
+	SigningKeyRevoked = "revoked" // скомпрометирован — подписи недействительны
+)
+
+// SigningKey — ключ Ed25519 платформы для подписи сертификатов. Активен ровно
+// один ключ; после ротации прежние остаются в JWKS, чтобы выданные ими
+// сертификаты по-прежнему проверялись.
+type SigningKey struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	KeyID     string    `gorm:"uniqueIndex;size:32" json:"kid"`
+	PublicKey []byte    `json:"-"`
+	// SealedSeed — приватная часть (seed), зашифрованная AES-GCM ключом из
+	// SIGNING_KEY_SECRET: дамп базы без секрета подписать ничего не даёт
+	SealedSeed []byte     `json:"-"`
+	Status     string     `gorm:"size:20;index;default:'active'" json:"status"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+}
@@ -10,4 +10,13 @@ type User struct {
 	RoleID   uint
 	Role     Role   `gorm:"foreignKey:RoleID"`
 	Language string `gorm:"size:5;default:'ru'"`
+
+	// Локальный вход: пустой хэш — пароля нет (только Google или ссылка из письма)
+	PasswordHash  string `json:"-"`
+	EmailVerified bool   `gorm:"not null;default:false" json:"verified_email"`
+
+	// Второй фактор: код из приложения-аутентификатора (TOTP)
+	TOTPSecret   string `gorm:"size:128" json:"-"` // зашифрован ключом из SIGNING_KEY_SECRET
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"two_factor_enabled"`
+	TOTPLastStep int64  `json:"-"` // последний принятый шаг: один код не сработает дважды
 }
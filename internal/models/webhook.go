@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// События, на которые можно подписать вебхук
+const (
+	EventEnrollmentCreated  = "enrollment.created"  // подана заявка или выполнена запись на курс
+	EventEnrollmentApproved = "enrollment.approved" // заявка одобрена
+	EventLessonCompleted    = "lesson.completed"    // ученик впервые завершил урок
+	EventCertificateIssued  = "certificate.issued"  // выдан сертификат
+	EventCourseApproved     = "course.approved"     // курс прошёл проверку администратора
+)
+
+// WebhookEvents lists all events in display order.
+var WebhookEvents = []string{
+	EventEnrollmentCreated,
+	EventEnrollmentApproved,
+	EventLessonCompleted,
+	EventCertificateIssued,
+	EventCourseApproved,
+}
+
+// Webhook — подписка внешней системы на события платформы. Без владельца
+// (OwnerID = nil) — общеплатформенная подписка администратора, иначе —
+// подписка автора на события его курсов.
+type Webhook struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OwnerID *uint    `gorm:"index" json:"owner_id"`
+	URL     string   `gorm:"not null" json:"url"`
+	Secret  string   `gorm:"size:64;not null" json:"-"` // ключ HMAC-подписи
+	Events  []string `gorm:"serializer:json" json:"events"`
+	Active  bool     `json:"active"`
+}
+
+// Subscribed reports whether the webhook wants the event.
+func (w Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Статусы доставки вебхука
+const (
+	DeliveryPending = "pending" // ждёт отправки или повтора
+	DeliverySuccess = "success"
+	DeliveryFailed  = "failed" // попытки исчерпаны
+)
+
+// WebhookDelivery — одна отправка события: тело запроса хранится, чтобы
+// повторная доставка отправила ровно то же самое.
+type WebhookDelivery struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	WebhookID     uint       `gorm:"index;not null" json:"webhook_id"`
+	Event         string     `gorm:"size:40;not null" json:"event"`
+	Payload       string     `gorm:"type:text" json:"payload"`
+	Status        string     `gorm:"size:20;index;not null" json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"`
+	ResponseCode  int        `json:"response_code"`
+	ResponseBody  string     `gorm:"type:text" json:"response_body"` // начало ответа
+	Error         string     `json:"error"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	RedeliveryOf  *uint      `json:"redelivery_of"` // исходная доставка при ручном повторе
+}
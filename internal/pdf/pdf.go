@@ -0,0 +1,349 @@
+// Package pdf writes simple single-purpose PDF documents: filled and stroked
+// rectangles, lines and Unicode text in embedded TrueType fonts. It is enough
+// for certificates and similar one-page printouts without external tools.
+//
+// Coordinates are in points with the origin in the top-left corner of the
+// page; text is positioned by its baseline.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Page sizes in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Color is an RGB color.
+type Color struct{ R, G, B uint8 }
+
+// HexColor parses "#rrggbb".
+func HexColor(s string) (Color, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return Color{}, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Color{}, false
+	}
+	return Color{uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+}
+
+// Document is a PDF under construction.
+type Document struct {
+	width, height float64
+	title         string
+	pages         []*Page
+	fonts         []*Font
+}
+
+// New creates an empty document whose pages have the given size.
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+// SetTitle sets the document title shown by viewers.
+func (d *Document) SetTitle(title string) { d.title = title }
+
+// Font is an embedded TrueType font. Only glyphs actually drawn are kept.
+type Font struct {
+	name  string
+	ttf   *trueType
+	used  map[uint16]rune
+	index int
+}
+
+// FontFile is a parsed TrueType font. It is never modified after parsing,
+// so one FontFile can be shared by any number of documents.
+type FontFile struct {
+	ttf *trueType
+}
+
+// ParseFont parses a TrueType font file.
+func ParseFont(data []byte) (*FontFile, error) {
+	ttf, err := parseTrueType(data)
+	if err != nil {
+		return nil, err
+	}
+	return &FontFile{ttf: ttf}, nil
+}
+
+// AddFont registers a TrueType font under a PostScript-style name.
+func (d *Document) AddFont(name string, data []byte) (*Font, error) {
+	file, err := ParseFont(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.UseFont(name, file), nil
+}
+
+// UseFont registers an already parsed font under a PostScript-style name.
+func (d *Document) UseFont(name string, file *FontFile) *Font {
+	f := &Font{name: name, ttf: file.ttf, used: make(map[uint16]rune), index: len(d.fonts) + 1}
+	d.fonts = append(d.fonts, f)
+	return f
+}
+
+// Width returns the advance width of s at the given size in points.
+func (f *Font) Width(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		total += f.ttf.advances[f.ttf.cmap[r]]
+	}
+	return float64(total) * size / float64(f.ttf.unitsPerEm)
+}
+
+func (f *Font) encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		g := f.ttf.cmap[r]
+		if _, ok := f.used[g]; !ok {
+			f.used[g] = r
+		}
+		fmt.Fprintf(&b, "%04X", g)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+// Page collects drawing operators of one page.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+	fonts   map[*Font]bool
+}
+
+// AddPage appends a blank page.
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d, fonts: make(map[*Font]bool)}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+func (p *Page) y(v float64) float64 { return p.doc.height - v }
+
+// SetFillColor sets the color for filled shapes and text.
+func (p *Page) SetFillColor(c Color) {
+	fmt.Fprintf(&p.content, "%s %s %s rg\n", num(float64(c.R)/255), num(float64(c.G)/255), num(float64(c.B)/255))
+}
+
+// SetStrokeColor sets the color for lines and outlines.
+func (p *Page) SetStrokeColor(c Color) {
+	fmt.Fprintf(&p.content, "%s %s %s RG\n", num(float64(c.R)/255), num(float64(c.G)/255), num(float64(c.B)/255))
+}
+
+// SetLineWidth sets the stroke width in points.
+func (p *Page) SetLineWidth(w float64) {
+	fmt.Fprintf(&p.content, "%s w\n", num(w))
+}
+
+// FillRect draws a filled rectangle with its top-left corner at x, y.
+func (p *Page) FillRect(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n", num(x), num(p.y(y+h)), num(w), num(h))
+}
+
+// StrokeRect draws a rectangle outline.
+func (p *Page) StrokeRect(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re S\n", num(x), num(p.y(y+h)), num(w), num(h))
+}
+
+// Line draws a straight line.
+func (p *Page) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "%s %s m %s %s l S\n", num(x1), num(p.y(y1)), num(x2), num(p.y(y2)))
+}
+
+// Text draws s with its baseline starting at x, y.
+func (p *Page) Text(f *Font, size, x, y float64, s string) {
+	p.fonts[f] = true
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td %s Tj ET\n", f.index, num(size), num(x), num(p.y(y)), f.encode(s))
+}
+
+// TextCenter draws s centered horizontally on cx.
+func (p *Page) TextCenter(f *Font, size, cx, y float64, s string) {
+	p.Text(f, size, cx-f.Width(s, size)/2, y, s)
+}
+
+// FitSize returns size, reduced if needed so that s fits into maxWidth.
+func (f *Font) FitSize(s string, size, maxWidth float64) float64 {
+	if w := f.Width(s, size); w > maxWidth && w > 0 {
+		return size * maxWidth / w
+	}
+	return size
+}
+
+// ─────────────────────────────────────────────
+// Serialization
+// ─────────────────────────────────────────────
+
+type writer struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *writer) reserve() int {
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+func (w *writer) object(id int, body string) {
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+func (w *writer) stream(id int, dict string, data []byte) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n<< %s /Filter /FlateDecode /Length %d >>\nstream\n", id, dict, z.Len())
+	w.buf.Write(z.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// Bytes serializes the document.
+func (d *Document) Bytes() []byte {
+	w := &writer{}
+	w.buf.WriteString("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+
+	catalog := w.reserve()
+	pagesID := w.reserve()
+
+	// Шрифты пишутся после страниц: набор глифов известен только после отрисовки
+	fontIDs := make(map[*Font]int, len(d.fonts))
+	for _, f := range d.fonts {
+		fontIDs[f] = w.reserve()
+	}
+
+	var kids []string
+	for _, p := range d.pages {
+		pageID := w.reserve()
+		contentID := w.reserve()
+		var res []string
+		for _, f := range d.fonts {
+			if p.fonts[f] {
+				res = append(res, fmt.Sprintf("/F%d %d 0 R", f.index, fontIDs[f]))
+			}
+		}
+		w.object(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pagesID, num(d.width), num(d.height), strings.Join(res, " "), contentID))
+		w.stream(contentID, "", p.content.Bytes())
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+	}
+
+	for _, f := range d.fonts {
+		d.writeFont(w, f, fontIDs[f])
+	}
+
+	w.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	w.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	info := w.reserve()
+	w.object(info, fmt.Sprintf("<< /Title %s /Producer (CoursePlatform) >>", textString(d.title)))
+
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, off := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(w.offsets)+1, catalog, info, xref)
+	return w.buf.Bytes()
+}
+
+func (d *Document) writeFont(w *writer, f *Font, id int) {
+	gids := make([]int, 0, len(f.used))
+	used := make(map[uint16]bool, len(f.used))
+	for g := range f.used {
+		gids = append(gids, int(g))
+		used[g] = true
+	}
+	sort.Ints(gids)
+
+	// Префикс подмножества: шесть заглавных букв, зависящих от набора глифов
+	h := sha1.New()
+	for _, g := range gids {
+		fmt.Fprintf(h, "%d,", g)
+	}
+	sum := h.Sum(nil)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	baseName := string(tag) + "+" + f.name
+
+	scale := func(v int) int { return v * 1000 / f.ttf.unitsPerEm }
+
+	var widths strings.Builder
+	for _, g := range gids {
+		fmt.Fprintf(&widths, "%d [%d] ", g, scale(f.ttf.advances[g]))
+	}
+
+	cidID := w.reserve()
+	descID := w.reserve()
+	fileID := w.reserve()
+	cmapID := w.reserve()
+
+	w.object(id, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		baseName, cidID, cmapID))
+	w.object(cidID, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>",
+		baseName, descID, widths.String()))
+	b := f.ttf.bbox
+	w.object(descID, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		baseName, scale(b[0]), scale(b[1]), scale(b[2]), scale(b[3]),
+		scale(f.ttf.ascent), scale(f.ttf.descent), scale(f.ttf.capHeight), fileID))
+
+	file := f.ttf.subset(used)
+	w.stream(fileID, fmt.Sprintf("/Length1 %d", len(file)), file)
+	w.stream(cmapID, "", toUnicodeCMap(gids, f.used))
+}
+
+func toUnicodeCMap(gids []int, runes map[uint16]rune) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(gids); start += 100 {
+		end := min(start+100, len(gids))
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, g := range gids[start:end] {
+			fmt.Fprintf(&b, "<%04X> <", g)
+			for _, u := range utf16.Encode([]rune{runes[uint16(g)]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// textString encodes s as a UTF-16BE PDF string.
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteByte('>')
+	return b.String()
+}
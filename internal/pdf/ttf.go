@@ -0,0 +1,319 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// trueType holds the parts of a TrueType font needed to measure text and to
+// embed a subset of it.
+type trueType struct {
+	tables     map[string][]byte
+	unitsPerEm int
+	numGlyphs  int
+	longLoca   bool
+	bbox       [4]int
+	ascent     int
+	descent    int
+	capHeight  int
+	advances   []int
+	cmap       map[rune]uint16
+}
+
+func parseTrueType(data []byte) (*trueType, error) {
+	if len(data) < 12 {
+		return nil, errors.New("pdf: font too short")
+	}
+	f := &trueType{tables: make(map[string][]byte)}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < n; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, errors.New("pdf: bad table directory")
+		}
+		tag := string(data[rec : rec+4])
+		off := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if off+length > len(data) {
+			return nil, fmt.Errorf("pdf: table %q out of range", tag)
+		}
+		f.tables[tag] = data[off : off+length]
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf", "cmap"} {
+		if f.tables[tag] == nil {
+			return nil, fmt.Errorf("pdf: font has no %q table", tag)
+		}
+	}
+
+	head := f.tables["head"]
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	f.longLoca = binary.BigEndian.Uint16(head[50:]) == 1
+	f.numGlyphs = int(binary.BigEndian.Uint16(f.tables["maxp"][4:]))
+
+	hhea := f.tables["hhea"]
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+
+	hmtx := f.tables["hmtx"]
+	f.advances = make([]int, f.numGlyphs)
+	last := 0
+	for g := 0; g < f.numGlyphs; g++ {
+		if g < numHMetrics && 4*g+2 <= len(hmtx) {
+			last = int(binary.BigEndian.Uint16(hmtx[4*g:]))
+		}
+		f.advances[g] = last
+	}
+
+	if err := f.parseCmap(); err != nil {
+		return nil, err
+	}
+
+	f.capHeight = f.ascent * 7 / 10
+	if g, ok := f.cmap['H']; ok {
+		if glyph := f.glyph(g); len(glyph) >= 10 {
+			f.capHeight = int(int16(binary.BigEndian.Uint16(glyph[8:])))
+		}
+	}
+	return f, nil
+}
+
+// parseCmap reads the Unicode BMP (format 4) or full (format 12) subtable.
+func (f *trueType) parseCmap() error {
+	cmap := f.tables["cmap"]
+	f.cmap = make(map[rune]uint16)
+	n := int(binary.BigEndian.Uint16(cmap[2:]))
+	var sub4, sub12 []byte
+	for i := 0; i < n; i++ {
+		rec := 4 + 8*i
+		platform := binary.BigEndian.Uint16(cmap[rec:])
+		encoding := binary.BigEndian.Uint16(cmap[rec+2:])
+		off := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if off >= len(cmap) {
+			continue
+		}
+		sub := cmap[off:]
+		switch binary.BigEndian.Uint16(sub) {
+		case 4:
+			if (platform == 3 && encoding == 1) || platform == 0 {
+				sub4 = sub
+			}
+		case 12:
+			if (platform == 3 && encoding == 10) || platform == 0 {
+				sub12 = sub
+			}
+		}
+	}
+
+	switch {
+	case sub12 != nil:
+		groups := int(binary.BigEndian.Uint32(sub12[12:]))
+		for i := 0; i < groups; i++ {
+			g := sub12[16+12*i:]
+			start := rune(binary.BigEndian.Uint32(g))
+			end := rune(binary.BigEndian.Uint32(g[4:]))
+			gid := binary.BigEndian.Uint32(g[8:])
+			for c := start; c <= end && c-start < 0x10000; c++ {
+				f.cmap[c] = uint16(gid + uint32(c-start))
+			}
+		}
+	case sub4 != nil:
+		segX2 := int(binary.BigEndian.Uint16(sub4[6:]))
+		ends := sub4[14:]
+		starts := sub4[16+segX2:]
+		deltas := sub4[16+2*segX2:]
+		rangeOffs := sub4[16+3*segX2:]
+		for s := 0; s < segX2/2; s++ {
+			end := int(binary.BigEndian.Uint16(ends[2*s:]))
+			start := int(binary.BigEndian.Uint16(starts[2*s:]))
+			delta := int(binary.BigEndian.Uint16(deltas[2*s:]))
+			ro := int(binary.BigEndian.Uint16(rangeOffs[2*s:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var gid int
+				if ro == 0 {
+					gid = (c + delta) & 0xFFFF
+				} else {
+					idx := 2*s + ro + 2*(c-start)
+					if idx+2 > len(rangeOffs) {
+						continue
+					}
+					gid = int(binary.BigEndian.Uint16(rangeOffs[idx:]))
+					if gid != 0 {
+						gid = (gid + delta) & 0xFFFF
+					}
+				}
+				if gid != 0 {
+					f.cmap[rune(c)] = uint16(gid)
+				}
+			}
+		}
+	default:
+		return errors.New("pdf: font has no Unicode cmap")
+	}
+	return nil
+}
+
+func (f *trueType) glyphRange(g uint16) (int, int) {
+	loca := f.tables["loca"]
+	i := int(g)
+	if f.longLoca {
+		if 4*i+8 > len(loca) {
+			return 0, 0
+		}
+		return int(binary.BigEndian.Uint32(loca[4*i:])), int(binary.BigEndian.Uint32(loca[4*i+4:]))
+	}
+	if 2*i+4 > len(loca) {
+		return 0, 0
+	}
+	return 2 * int(binary.BigEndian.Uint16(loca[2*i:])), 2 * int(binary.BigEndian.Uint16(loca[2*i+2:]))
+}
+
+func (f *trueType) glyph(g uint16) []byte {
+	start, end := f.glyphRange(g)
+	glyf := f.tables["glyf"]
+	if start >= end || end > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// components lists the glyphs a composite glyph is built from.
+func (f *trueType) components(g uint16) []uint16 {
+	glyph := f.glyph(g)
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil
+	}
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	var out []uint16
+	p := 10
+	for p+4 <= len(glyph) {
+		flags := binary.BigEndian.Uint16(glyph[p:])
+		out = append(out, binary.BigEndian.Uint16(glyph[p+2:]))
+		p += 4
+		if flags&argsAreWords != 0 {
+			p += 4
+		} else {
+			p += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			p += 2
+		case flags&haveXYScale != 0:
+			p += 4
+		case flags&haveTwoByTwo != 0:
+			p += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return out
+}
+
+// subset returns a font file keeping glyph ids unchanged but with the outlines
+// of unused glyphs dropped, plus only the tables a PDF viewer needs.
+func (f *trueType) subset(used map[uint16]bool) []byte {
+	keep := map[uint16]bool{0: true}
+	var walk func(g uint16)
+	walk = func(g uint16) {
+		if keep[g] && g != 0 {
+			return
+		}
+		keep[g] = true
+		for _, c := range f.components(g) {
+			walk(c)
+		}
+	}
+	for g := range used {
+		walk(g)
+	}
+
+	var glyf []byte
+	loca := make([]byte, 4*(f.numGlyphs+1))
+	for g := 0; g < f.numGlyphs; g++ {
+		binary.BigEndian.PutUint32(loca[4*g:], uint32(len(glyf)))
+		if keep[uint16(g)] {
+			glyf = append(glyf, f.glyph(uint16(g))...)
+			for len(glyf)%4 != 0 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*f.numGlyphs:], uint32(len(glyf)))
+
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0) // checkSumAdjustment
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{
+		"head": head,
+		"hhea": f.tables["hhea"],
+		"hmtx": f.tables["hmtx"],
+		"maxp": f.tables["maxp"],
+		"loca": loca,
+		"glyf": glyf,
+	}
+	for _, tag := range []string{"cvt ", "fpgm", "prep"} {
+		if t := f.tables[tag]; t != nil {
+			tables[tag] = t
+		}
+	}
+	return writeTrueType(tables)
+}
+
+func writeTrueType(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	out := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(out, 0x00010000)
+	binary.BigEndian.PutUint16(out[4:], uint16(n))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*n-searchRange))
+
+	for i, tag := range tags {
+		t := tables[tag]
+		rec := out[12+16*i:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[4:], tableChecksum(t))
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(t)))
+		out = append(out, t...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+func tableChecksum(t []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(t); i += 4 {
+		var word [4]byte
+		copy(word[:], t[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,180 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func loadTestFont(t *testing.T) *trueType {
+	t.Helper()
+	data, err := os.ReadFile("../certpdf/fonts/DejaVuSans.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := parseTrueType(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// readTables parses a font file written by writeTrueType and checks its
+// table directory: sorted tags, 4-byte aligned offsets, valid checksums.
+func readTables(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	if len(data) < 12 || binary.BigEndian.Uint32(data) != 0x00010000 {
+		t.Fatal("subset: not a TrueType file")
+	}
+	n := int(binary.BigEndian.Uint16(data[4:]))
+	tables := make(map[string][]byte, n)
+	prev := ""
+	for i := 0; i < n; i++ {
+		rec := data[12+16*i:]
+		tag := string(rec[:4])
+		sum := binary.BigEndian.Uint32(rec[4:])
+		off := int(binary.BigEndian.Uint32(rec[8:]))
+		length := int(binary.BigEndian.Uint32(rec[12:]))
+		if tag <= prev {
+			t.Errorf("subset: table %q out of order", tag)
+		}
+		prev = tag
+		if off%4 != 0 || off+length > len(data) {
+			t.Fatalf("subset: table %q at %d+%d out of range", tag, off, length)
+		}
+		tables[tag] = data[off : off+length]
+		if got := tableChecksum(tables[tag]); got != sum {
+			t.Errorf("subset: table %q checksum %08x, directory says %08x", tag, got, sum)
+		}
+	}
+	return tables
+}
+
+func TestSubsetRoundTrip(t *testing.T) {
+	f := loadTestFont(t)
+
+	// «й» в DejaVu — составной глиф, его компоненты должны попасть в подмножество
+	used := map[uint16]bool{}
+	for _, r := range "Hello, Мир й" {
+		used[f.cmap[r]] = true
+	}
+	composite := f.cmap['й']
+	if len(f.components(composite)) == 0 {
+		t.Fatal("test font: expected a composite glyph for й")
+	}
+
+	sub := &trueType{tables: readTables(t, f.subset(used)), numGlyphs: f.numGlyphs, longLoca: true}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "loca", "glyf"} {
+		if sub.tables[tag] == nil {
+			t.Errorf("subset: missing %q table", tag)
+		}
+	}
+	if _, ok := sub.tables["cmap"]; ok {
+		t.Error("subset: cmap should be dropped, the PDF maps CIDs to glyphs directly")
+	}
+	if got := binary.BigEndian.Uint16(sub.tables["head"][50:]); got != 1 {
+		t.Errorf("subset: indexToLocFormat = %d, want 1 (long loca)", got)
+	}
+	if got := len(sub.tables["loca"]); got != 4*(f.numGlyphs+1) {
+		t.Errorf("subset: loca has %d bytes, want %d", got, 4*(f.numGlyphs+1))
+	}
+	if !bytes.Equal(sub.tables["hmtx"], f.tables["hmtx"]) {
+		t.Error("subset: hmtx changed, glyph ids must stay the same")
+	}
+
+	keep := map[uint16]bool{0: true}
+	for g := range used {
+		keep[g] = true
+		for _, c := range f.components(g) {
+			keep[c] = true
+		}
+	}
+	for g := 0; g < f.numGlyphs; g++ {
+		got, want := sub.glyph(uint16(g)), f.glyph(uint16(g))
+		if !keep[uint16(g)] {
+			if len(got) != 0 {
+				t.Fatalf("subset: glyph %d should be empty, has %d bytes", g, len(got))
+			}
+			continue
+		}
+		// Глифы дополняются нулями до границы 4 байт
+		if len(got) < len(want) || !bytes.Equal(got[:len(want)], want) || len(got)-len(want) > 3 {
+			t.Errorf("subset: glyph %d differs from the original", g)
+		}
+	}
+}
+
+func TestSubsetEmpty(t *testing.T) {
+	f := loadTestFont(t)
+	sub := &trueType{tables: readTables(t, f.subset(nil)), numGlyphs: f.numGlyphs, longLoca: true}
+	if len(sub.glyph(0)) == 0 && len(f.glyph(0)) != 0 {
+		t.Error("subset: .notdef must always be kept")
+	}
+	for g := 1; g < f.numGlyphs; g++ {
+		if len(sub.glyph(uint16(g))) != 0 {
+			t.Fatalf("subset: glyph %d kept without being used", g)
+		}
+	}
+}
+
+func TestParseTrueTypeRejectsGarbage(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("not a font"), make([]byte, 64)} {
+		if _, err := parseTrueType(data); err == nil {
+			t.Errorf("parseTrueType(%q): expected an error", data)
+		}
+	}
+}
+
+// Разобранный FontFile общий для документов, набор глифов у каждого свой.
+func TestSharedFontFile(t *testing.T) {
+	data, err := os.ReadFile("../certpdf/fonts/DejaVuSans.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := ParseFont(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := New(A4Width, A4Height), New(A4Width, A4Height)
+	fa, fb := a.UseFont("DejaVuSans", file), b.UseFont("DejaVuSans", file)
+	a.AddPage().Text(fa, 12, 10, 10, "AAA")
+	b.AddPage().Text(fb, 12, 10, 10, "Zz")
+
+	if len(fa.used) != 1 || len(fb.used) != 2 {
+		t.Errorf("glyph sets leaked between documents: %d and %d", len(fa.used), len(fb.used))
+	}
+	if fa.Width("AAA", 10) != fb.Width("AAA", 10) {
+		t.Error("same font file measures differently")
+	}
+
+	for _, doc := range []*Document{a, b} {
+		out := doc.Bytes()
+		if !bytes.HasPrefix(out, []byte("%PDF-1.7\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+			t.Fatal("document is not framed as a PDF")
+		}
+		m := regexp.MustCompile(`/Length1 (\d+) /Filter /FlateDecode /Length (\d+) >>\nstream\n`).FindSubmatchIndex(out)
+		if m == nil {
+			t.Fatal("no embedded font file")
+		}
+		length1, _ := strconv.Atoi(string(out[m[2]:m[3]]))
+		length, _ := strconv.Atoi(string(out[m[4]:m[5]]))
+		zr, err := zlib.NewReader(bytes.NewReader(out[m[1] : m[1]+length]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		font, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(font) != length1 {
+			t.Errorf("embedded font is %d bytes, /Length1 says %d", len(font), length1)
+		}
+		readTables(t, font)
+	}
+}
@@ -0,0 +1,246 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Пример из ISO/IEC 18004, приложение I: «01234567», версия 1-M.
+func TestReedSolomonKnownAnswer(t *testing.T) {
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("ECC = % X, want % X", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	// Строки формата уровня M для масок 0–7 из таблицы стандарта
+	formats := []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	}
+	for mask, want := range formats {
+		q := newSymbol(1)
+		q.drawFormatBits(mask)
+		if got := readFormat(q.modules); got != want {
+			t.Errorf("mask %d: format bits %s, want %s", mask, got, want)
+		}
+	}
+
+	q := newSymbol(7)
+	q.drawVersion()
+	var got strings.Builder
+	for i := 17; i >= 0; i-- {
+		if q.modules[i/3][q.size-11+i%3] {
+			got.WriteByte('1')
+		} else {
+			got.WriteByte('0')
+		}
+	}
+	if want := "000111110010010100"; got.String() != want {
+		t.Errorf("version 7 bits %s, want %s", got.String(), want)
+	}
+}
+
+// readFormat reads the copy of the format bits around the top-left finder,
+// most significant bit first.
+func readFormat(m [][]bool) string {
+	var pos [15][2]int
+	for i := 0; i <= 5; i++ {
+		pos[i] = [2]int{8, i}
+	}
+	pos[6], pos[7], pos[8] = [2]int{8, 7}, [2]int{8, 8}, [2]int{7, 8}
+	for i := 9; i < 15; i++ {
+		pos[i] = [2]int{14 - i, 8}
+	}
+	var b strings.Builder
+	for i := 14; i >= 0; i-- {
+		if m[pos[i][1]][pos[i][0]] {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String()
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"a",
+		"https://example.com/certificates/verify/ABCD-1234",
+		"Проверка сертификата: " + strings.Repeat("x", 60),
+		strings.Repeat("0123456789", 21), // версия 10
+	}
+	for _, in := range inputs {
+		code, err := Encode([]byte(in))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", len(in), err)
+		}
+		got, err := decode(code)
+		if err != nil {
+			t.Fatalf("decode(%d bytes): %v", len(in), err)
+		}
+		if got != in {
+			t.Errorf("round trip: got %q, want %q", got, in)
+		}
+	}
+}
+
+func TestEncodeSmallestVersion(t *testing.T) {
+	// Ёмкость 1-M в байтовом режиме — 14 байт, 10-M — 213
+	for _, tc := range []struct{ n, size int }{{14, 21}, {15, 25}, {213, 57}} {
+		code, err := Encode(bytes.Repeat([]byte{'a'}, tc.n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.Size != tc.size || len(code.Modules) != tc.size {
+			t.Errorf("%d bytes: size %d, want %d", tc.n, code.Size, tc.size)
+		}
+	}
+	if _, err := Encode(bytes.Repeat([]byte{'a'}, 214)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("214 bytes: err = %v, want ErrTooLong", err)
+	}
+}
+
+func TestFinderPatterns(t *testing.T) {
+	code, err := Encode([]byte("finder"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := code.Size
+	for _, c := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if want := ring != 2; code.Modules[c[1]+dy][c[0]+dx] != want {
+					t.Fatalf("finder at %v: module (%d,%d) wrong", c, dx, dy)
+				}
+			}
+		}
+	}
+	for i := 8; i < n-8; i++ {
+		if code.Modules[6][i] != (i%2 == 0) || code.Modules[i][6] != (i%2 == 0) {
+			t.Fatalf("timing pattern broken at %d", i)
+		}
+	}
+}
+
+// decode reads a symbol back the way a scanner would: format bits, unmasking,
+// codeword placement, de-interleaving and the Reed–Solomon check per block.
+// Function modules are taken from a fresh symbol of the same version.
+func decode(code *Code) (string, error) {
+	version := (code.Size - 17) / 4
+	format := readFormat(code.Modules)
+	if format[:2] != "10" { // 00 уровня M после XOR с 0x5412
+		return "", errors.New("not level M")
+	}
+	mask := int(format[2]-'0')<<2 | int(format[3]-'0')<<1 | int(format[4]-'0')
+	mask ^= 0x5 // XOR-маска формата 101
+
+	ref := newSymbol(version)
+	ref.drawFunctionPatterns()
+
+	var bits []bool
+	for right := code.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < code.Size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = code.Size - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if ref.isFunction[y][x] {
+					continue
+				}
+				bits = append(bits, code.Modules[y][x] != maskBit(mask, x, y))
+			}
+		}
+	}
+	raw := make([]byte, rawModules(version)/8)
+	for i := range raw {
+		for j := 0; j < 8; j++ {
+			if bits[8*i+j] {
+				raw[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	blocks, ecc := numBlocks[version], eccPerBlock[version]
+	shortLen := len(raw) / blocks
+	numShort := blocks - len(raw)%blocks
+	data := make([][]byte, blocks)
+	k := 0
+	for i := 0; i < shortLen-ecc+1; i++ {
+		for b := range data {
+			if i < shortLen-ecc || b >= numShort {
+				data[b] = append(data[b], raw[k])
+				k++
+			}
+		}
+	}
+	var payload []byte
+	for b := range data {
+		block := data[b]
+		checks := make([]byte, ecc)
+		for i := range checks {
+			checks[i] = raw[k+i*blocks+b]
+		}
+		if !bytes.Equal(rsRemainder(block, rsDivisor(ecc)), checks) {
+			return "", errors.New("Reed–Solomon check failed")
+		}
+		payload = append(payload, block...)
+	}
+
+	r := bitReader{data: payload}
+	if r.read(4) != 0x4 {
+		return "", errors.New("not byte mode")
+	}
+	n := r.read(countBits(version))
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(r.read(8))
+	}
+	return string(out), nil
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := r.data[r.pos>>3] >> (7 - uint(r.pos&7)) & 1
+		v = v<<1 | int(bit)
+		r.pos++
+	}
+	return v
+}
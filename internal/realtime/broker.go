@@ -0,0 +1,101 @@
+// Package realtime pushes live updates to browsers over Server-Sent Events.
+package realtime
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Event is a message delivered to subscribers. Type becomes the SSE event
+// name, Data is sent as JSON.
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Broker delivers events to subscribers of named channels. The in-process
+// MemoryBroker serves a single instance; PostgresBroker fans events out
+// across instances via LISTEN/NOTIFY.
+type Broker interface {
+	// Publish sends e to every subscriber of any of the channels, once per
+	// subscriber. Slow subscribers miss events instead of blocking.
+	Publish(e Event, channels ...string)
+	// Subscribe returns a stream of events for the channels and a function
+	// that cancels the subscription.
+	Subscribe(channels ...string) (<-chan Event, func())
+}
+
+// AdminsChannel receives events for all administrators.
+const AdminsChannel = "admins"
+
+// UserChannel is the personal channel of a user.
+func UserChannel(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// CourseChannel receives events about a course's comments and reactions.
+func CourseChannel(courseID uint) string {
+	return fmt.Sprintf("course:%d", courseID)
+}
+
+const bufferSize = 16
+
+type subscriber struct {
+	ch chan Event
+}
+
+// MemoryBroker is an in-process Broker.
+type MemoryBroker struct {
+	mu   sync.RWMutex
+	subs map[string]map[*subscriber]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: make(map[string]map[*subscriber]struct{})}
+}
+
+func (b *MemoryBroker) Publish(e Event, channels ...string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	sent := make(map[*subscriber]bool)
+	for _, name := range channels {
+		for s := range b.subs[name] {
+			if sent[s] {
+				continue
+			}
+			sent[s] = true
+			select {
+			case s.ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+func (b *MemoryBroker) Subscribe(channels ...string) (<-chan Event, func()) {
+	s := &subscriber{ch: make(chan Event, bufferSize)}
+
+	b.mu.Lock()
+	for _, name := range channels {
+		if b.subs[name] == nil {
+			b.subs[name] = make(map[*subscriber]struct{})
+		}
+		b.subs[name][s] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			for _, name := range channels {
+				delete(b.subs[name], s)
+				if len(b.subs[name]) == 0 {
+					delete(b.subs, name)
+				}
+			}
+			b.mu.Unlock()
+		})
+	}
+}
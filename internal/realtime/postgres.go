@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// pgChannel is the Postgres NOTIFY channel shared by all instances.
+const pgChannel = "realtime_events"
+
+// PostgresBroker publishes events with NOTIFY so that every application
+// instance listening on the database delivers them to its own subscribers.
+// Payloads are limited by Postgres to ~8 KB, so events should carry IDs
+// rather than whole objects.
+type PostgresBroker struct {
+	db    *gorm.DB
+	local *MemoryBroker
+}
+
+type envelope struct {
+	Channels []string        `json:"channels"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// NewPostgresBroker opens a dedicated LISTEN connection using dsn and keeps
+// it open (reconnecting on errors) until ctx is cancelled. Notifications are
+// sent through db.
+func NewPostgresBroker(ctx context.Context, dsn string, db *gorm.DB) (*PostgresBroker, error) {
+	conn, err := listen(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	b := &PostgresBroker{db: db, local: NewMemoryBroker()}
+	go b.run(ctx, dsn, conn)
+	return b, nil
+}
+
+func listen(ctx context.Context, dsn string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (b *PostgresBroker) run(ctx context.Context, dsn string, conn *pgx.Conn) {
+	for {
+		for conn == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			var err error
+			if conn, err = listen(ctx, dsn); err != nil {
+				log.Printf("realtime: LISTEN reconnect failed: %v", err)
+			}
+		}
+
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			conn.Close(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("realtime: LISTEN connection lost: %v", err)
+			conn = nil
+			continue
+		}
+
+		var env envelope
+		if err := json.Unmarshal([]byte(n.Payload), &env); err != nil {
+			log.Printf("realtime: bad payload: %v", err)
+			continue
+		}
+		b.local.Publish(Event{Type: env.Type, Data: env.Data}, env.Channels...)
+	}
+}
+
+func (b *PostgresBroker) Publish(e Event, channels ...string) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		log.Printf("realtime: encode %s: %v", e.Type, err)
+		return
+	}
+	payload, _ := json.Marshal(envelope{Channels: channels, Type: e.Type, Data: data})
+	if err := b.db.Exec("SELECT pg_notify(?, ?)", pgChannel, string(payload)).Error; err != nil {
+		log.Printf("realtime: NOTIFY %s: %v", e.Type, err)
+	}
+}
+
+func (b *PostgresBroker) Subscribe(channels ...string) (<-chan Event, func()) {
+	return b.local.Subscribe(channels...)
+}
@@ -0,0 +1,161 @@
+// Package session keeps browser sessions in Postgres: the cookie carries
+// only a signed random token, so a session can be listed per device and
+// revoked on the server.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/s/onlineCourse/internal/models"
+	"github.com/s/onlineCourse/internal/storage"
+	"gorm.io/gorm"
+)
+
+// touchInterval limits writes of last_seen_at to one per session per interval.
+const touchInterval = 5 * time.Minute
+
+// Store is a gorilla sessions.Store backed by the sessions table.
+type Store struct {
+	DB      *gorm.DB
+	Codecs  []securecookie.Codec
+	Options *sessions.Options // по умолчанию для новых сессий
+}
+
+// NewStore signs the session cookie with keyPairs, as sessions.NewCookieStore does.
+func NewStore(db *gorm.DB, keyPairs ...[]byte) *Store {
+	return &Store{
+		DB:     db,
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   86400 * 7,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+	}
+}
+
+// Get returns the session cached for the request.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session of the request cookie. A missing, forged, expired
+// or revoked session gives a new empty one.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var token string
+	if err := securecookie.DecodeMulti(name, c.Value, &token, s.Codecs...); err != nil {
+		return session, nil
+	}
+	row, err := storage.LoadSession(s.DB, token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := (securecookie.GobEncoder{}).Deserialize(row.Data, &session.Values); err != nil {
+		return session, err
+	}
+	session.ID = token
+	session.IsNew = false
+	if time.Since(row.LastSeenAt) > touchInterval {
+		storage.TouchSession(s.DB, row.ID)
+	}
+	return session, nil
+}
+
+// Save writes the session and its cookie. A negative MaxAge ends the
+// session; an empty guest session is not stored at all. Only a session
+// without an ID is inserted: an existing one that was revoked while the
+// request ran stays revoked and its cookie is cleared.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := storage.DeleteSession(s.DB, session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	created := session.ID == ""
+	if created {
+		if len(session.Values) == 0 {
+			return nil
+		}
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		session.ID = hex.EncodeToString(b)
+	}
+
+	data, err := securecookie.GobEncoder{}.Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+	ttl := session.Options.MaxAge
+	if ttl == 0 {
+		ttl = s.Options.MaxAge // cookie до закрытия браузера, в базе — обычный срок
+	}
+	userID, _ := session.Values["user_id"].(uint)
+	if adminID, ok := session.Values["impersonator_id"].(uint); ok {
+		userID = adminID // «просмотр от имени»: сессия остаётся за админом
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	row := models.Session{
+		UserID:    userID,
+		Data:      data,
+		UserAgent: userAgent,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+	if created {
+		err = storage.CreateSession(s.DB, session.ID, row)
+	} else {
+		err = storage.UpdateSession(s.DB, session.ID, row)
+	}
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		session.ID = ""
+		session.Values = make(map[interface{}]interface{})
+		expired := *session.Options
+		expired.MaxAge = -1
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", &expired))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix starts every personal token, so that leaked tokens are easy
+// to find with secret scanners.
+const APITokenPrefix = "ocp_"
+
+var (
+	ErrAPITokenName       = errors.New("token name is required (up to 100 characters)")
+	ErrAPITokenScopes     = errors.New("choose at least one known scope")
+	ErrAPITokenAdminScope = errors.New("only administrators can create tokens with the admin scope")
+	ErrAPITokenNotFound   = errors.New("token not found")
+	ErrInvalidAPIToken    = errors.New("invalid or revoked API token")
+)
+
+// apiTokenTouchInterval limits writes of last_used_at for busy scripts.
+const apiTokenTouchInterval = time.Minute
+
+// CreateAPIToken issues a personal token. The raw token is returned once;
+// only its hash is stored.
+func CreateAPIToken(db *gorm.DB, user models.User, name string, scopes []string) (string, models.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", models.APIToken{}, ErrAPITokenName
+	}
+	var granted []string
+	for _, known := range models.APIScopes {
+		for _, s := range scopes {
+			if s == known {
+				granted = append(granted, known)
+				break
+			}
+		}
+	}
+	if len(granted) == 0 || len(granted) != len(scopes) {
+		return "", models.APIToken{}, ErrAPITokenScopes
+	}
+	token := models.APIToken{UserID: user.ID, Name: name, Scopes: strings.Join(granted, " ")}
+	if token.HasScope(models.ScopeAdmin) && user.RoleID < models.RoleAdmin {
+		return "", models.APIToken{}, ErrAPITokenAdminScope
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", token, err
+	}
+	raw := APITokenPrefix + hex.EncodeToString(b)
+	token.Prefix = raw[:len(APITokenPrefix)+6]
+	token.TokenHash = hashToken(raw)
+	return raw, token, db.Create(&token).Error
+}
+
+// AuthenticateAPIToken finds the live token of an Authorization header and
+// records its use.
+func AuthenticateAPIToken(db *gorm.DB, raw, ip string) (models.APIToken, error) {
+	var token models.APIToken
+	err := db.Where("token_hash = ? AND revoked_at IS NULL", hashToken(raw)).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return token, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return token, err
+	}
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > apiTokenTouchInterval || token.LastUsedIP != ip {
+		now := time.Now()
+		db.Model(&token).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+	return token, nil
+}
+
+// UserAPITokens lists the live tokens of a user, newest first.
+func UserAPITokens(db *gorm.DB, userID uint) ([]models.APIToken, error) {
+	var list []models.APIToken
+	err := db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+// RevokeAPIToken disables a token of the user at once.
+func RevokeAPIToken(db *gorm.DB, userID, tokenID uint) error {
+	res := db.Model(&models.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+const maxAnswerLength = 5000
+
+// ApplicationAnswerInput is one answer sent by a learner with an enrollment
+// request. Value is a JSON string, or an array of strings for multichoice.
+type ApplicationAnswerInput struct {
+	FieldID uint            `json:"field_id"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// ApplicationFields returns the application form of a course in display order.
+func ApplicationFields(db *gorm.DB, courseID uint) []models.ApplicationField {
+	var fields []models.ApplicationField
+	db.Where("course_id = ?", courseID).Order(`"order" asc, id asc`).Find(&fields)
+	return fields
+}
+
+// ApplicationUploadDir is where the files a learner attaches to a course's
+// application form are stored. A file answer must point inside it, so one
+// cannot hand in another user's upload.
+func ApplicationUploadDir(courseID, userID uint) string {
+	return fmt.Sprintf("uploads/applications/%d/%d", courseID, userID)
+}
+
+// ValidateApplication checks learner answers against the course form and
+// returns the rows to store. Errors are meant to be shown to the learner.
+func ValidateApplication(fields []models.ApplicationField, userID uint, inputs []ApplicationAnswerInput) ([]models.EnrollmentAnswer, error) {
+	byField := make(map[uint]json.RawMessage, len(inputs))
+	for _, in := range inputs {
+		byField[in.FieldID] = in.Value
+	}
+
+	answers := make([]models.EnrollmentAnswer, 0, len(fields))
+	for _, f := range fields {
+		raw := byField[f.ID]
+		delete(byField, f.ID)
+
+		value, err := normalizeAnswer(f, userID, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Label, err)
+		}
+		if value == nil {
+			if f.Required {
+				return nil, fmt.Errorf("%s: required", f.Label)
+			}
+			continue
+		}
+		answers = append(answers, models.EnrollmentAnswer{
+			FieldID: f.ID,
+			Type:    f.Type,
+			Label:   f.Label,
+			Value:   value,
+		})
+	}
+
+	for id := range byField {
+		return nil, fmt.Errorf("unknown field %d", id)
+	}
+	return answers, nil
+}
+
+// normalizeAnswer validates a single value; nil means "no answer".
+func normalizeAnswer(f models.ApplicationField, userID uint, raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var options []string
+	if len(f.Options) > 0 {
+		json.Unmarshal(f.Options, &options)
+	}
+	isOption := func(v string) bool {
+		for _, o := range options {
+			if o == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	if f.Type == "multichoice" {
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("expected a list of options")
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		for _, v := range values {
+			if !isOption(v) {
+				return nil, fmt.Errorf("unknown option %q", v)
+			}
+		}
+		return json.Marshal(values)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("expected text")
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	switch f.Type {
+	case "text", "textarea":
+		if utf8.RuneCountInString(value) > maxAnswerLength {
+			return nil, fmt.Errorf("too long (max %d characters)", maxAnswerLength)
+		}
+	case "choice":
+		if !isOption(value) {
+			return nil, fmt.Errorf("unknown option %q", value)
+		}
+	case "file":
+		// файл загружается заранее через /api/courses/{id}/application-form/upload
+		name, ok := strings.CutPrefix(value, "/"+ApplicationUploadDir(f.CourseID, userID)+"/")
+		if !ok || name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+			return nil, fmt.Errorf("invalid file")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported field type")
+	}
+	return json.Marshal(value)
+}
+
+// SaveApplicationAnswers replaces the stored answers of an enrollment.
+func SaveApplicationAnswers(db *gorm.DB, enrollmentID uint, answers []models.EnrollmentAnswer) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("enrollment_id = ?", enrollmentID).Delete(&models.EnrollmentAnswer{}).Error; err != nil {
+			return err
+		}
+		for i := range answers {
+			answers[i].ID = 0
+			answers[i].EnrollmentID = enrollmentID
+		}
+		if len(answers) == 0 {
+			return nil
+		}
+		return tx.Create(&answers).Error
+	})
+}
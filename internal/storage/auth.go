@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Password length limits; bcrypt ignores everything after 72 bytes.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+var (
+	ErrBadEmail         = errors.New("invalid email address")
+	ErrEmailTaken       = errors.New("email is already registered")
+	ErrWeakPassword     = errors.New("password must be 8 to 72 characters long")
+	ErrBadCredentials   = errors.New("wrong email or password")
+	ErrEmailNotVerified = errors.New("email is not verified")
+	ErrInvalidAuthToken = errors.New("link is invalid or expired")
+)
+
+// dummyHash is compared against when the account does not exist, so that
+// a failed login takes as long for unknown emails as for wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("no such user"), bcrypt.DefaultCost)
+
+// CheckPassword validates the length of a new password.
+func CheckPassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if err := CheckPassword(password); err != nil {
+		return "", err
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(h), err
+}
+
+// ValidEmail reports whether s is a bare address like user@example.com.
+func ValidEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// FindUserByEmail looks a user up by address, ignoring case.
+func FindUserByEmail(db *gorm.DB, email string) (models.User, error) {
+	var user models.User
+	err := db.Where("LOWER(email) = ?", NormalizeEmail(email)).First(&user).Error
+	return user, err
+}
+
+// RegisterLocalUser creates an account with a password. The address stays
+// unverified until the link from the confirmation letter is opened.
+func RegisterLocalUser(db *gorm.DB, email, name, password string) (models.User, error) {
+	email = NormalizeEmail(email)
+	if !ValidEmail(email) {
+		return models.User{}, ErrBadEmail
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+	if _, err := FindUserByEmail(db, email); err == nil {
+		return models.User{}, ErrEmailTaken
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = email[:strings.Index(email, "@")]
+	}
+	user := models.User{Email: email, Name: name, PasswordHash: hash}
+	if err := createUser(db, &user); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+// AuthenticateLocal checks an email and password.
+func AuthenticateLocal(db *gorm.DB, email, password string) (models.User, error) {
+	user, err := FindUserByEmail(db, email)
+	if err != nil || user.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return models.User{}, ErrBadCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return models.User{}, ErrBadCredentials
+	}
+	if !user.EmailVerified {
+		return user, ErrEmailNotVerified
+	}
+	return user, nil
+}
+
+// CreateAuthToken issues a one-time token for the e-mail link. Only its
+// hash is stored.
+func CreateAuthToken(db *gorm.DB, purpose, email string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := hex.EncodeToString(b)
+	err := db.Create(&models.AuthToken{
+		Purpose:   purpose,
+		Email:     NormalizeEmail(email),
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+	return raw, err
+}
+
+// ConsumeAuthToken marks the token used and returns the address it was
+// issued for. A token works once and only before it expires.
+func ConsumeAuthToken(db *gorm.DB, purpose, raw string) (string, error) {
+	var token models.AuthToken
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.AuthToken{}).
+			Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hashToken(raw), purpose, time.Now()).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidAuthToken
+		}
+		return tx.Where("token_hash = ?", hashToken(raw)).First(&token).Error
+	})
+	return token.Email, err
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyEmail confirms the address of the account after the link from the
+// letter was opened, and claims invitations sent to it.
+func VerifyEmail(db *gorm.DB, email string) (models.User, error) {
+	user, err := FindUserByEmail(db, email)
+	if err != nil {
+		return user, err
+	}
+	if !user.EmailVerified {
+		if err := db.Model(&user).Update("email_verified", true).Error; err != nil {
+			return user, err
+		}
+		ClaimPendingInvitations(db, user)
+	}
+	return user, nil
+}
+
+// ResetPassword sets a new password. Having the reset link proves the
+// address too.
+func ResetPassword(db *gorm.DB, email, password string) (models.User, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+	user, err := VerifyEmail(db, email)
+	if err != nil {
+		return user, err
+	}
+	return user, db.Model(&user).Update("password_hash", hash).Error
+}
+
+// MagicLinkUser returns the account for a magic-link login, creating a
+// passwordless one for a new address. The password of an unverified account
+// is dropped: anyone could have registered the address and chosen it.
+func MagicLinkUser(db *gorm.DB, email string) (models.User, error) {
+	user, err := FindUserByEmail(db, email)
+	if err == nil {
+		if !user.EmailVerified {
+			if err := db.Model(&user).Updates(map[string]interface{}{"email_verified": true, "password_hash": ""}).Error; err != nil {
+				return user, err
+			}
+			user.EmailVerified, user.PasswordHash = true, ""
+			ClaimPendingInvitations(db, user)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+	email = NormalizeEmail(email)
+	user = models.User{Email: email, Name: email[:strings.Index(email, "@")], EmailVerified: true}
+	return user, createUser(db, &user)
+}
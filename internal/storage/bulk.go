@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BulkEnrollRow is one line of an imported list of learners.
+type BulkEnrollRow struct {
+	Line   int
+	Email  string
+	Name   string
+	Cohort string
+}
+
+// BulkEnrollResult reports what happened to a single imported row.
+type BulkEnrollResult struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Result string `json:"result"` // enrolled | already_enrolled | invited | error
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkEnroll pre-approves every listed learner on the course. Existing users
+// get an approved enrollment right away; unknown emails are stored as
+// pending invitations and claimed on first login.
+func BulkEnroll(db *gorm.DB, course models.Course, rows []BulkEnrollRow, invitedByID uint) []BulkEnrollResult {
+	results := make([]BulkEnrollResult, 0, len(rows))
+	seen := make(map[string]bool, len(rows))
+
+	for _, row := range rows {
+		res := BulkEnrollResult{Line: row.Line, Email: row.Email}
+
+		addr, err := mail.ParseAddress(row.Email)
+		if err != nil || addr.Address != row.Email {
+			res.Result, res.Error = "error", "invalid email"
+			results = append(results, res)
+			continue
+		}
+		email := strings.ToLower(row.Email)
+		res.Email = email
+		if seen[email] {
+			res.Result, res.Error = "error", "duplicate row"
+			results = append(results, res)
+			continue
+		}
+		seen[email] = true
+
+		var user models.User
+		err = db.Where("LOWER(email) = ?", email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			inv := models.PendingInvitation{
+				Email:       email,
+				CourseID:    course.ID,
+				Name:        row.Name,
+				Cohort:      row.Cohort,
+				InvitedByID: invitedByID,
+			}
+			if err := db.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "email"}, {Name: "course_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"name", "cohort", "invited_by_id"}),
+			}).Create(&inv).Error; err != nil {
+				res.Result, res.Error = "error", "database error"
+			} else {
+				res.Result = "invited"
+			}
+		case err != nil:
+			res.Result, res.Error = "error", "database error"
+		default:
+			_, already, err := approveEnrollment(db, user.ID, course, row.Cohort, invitedByID, "bulk import")
+			switch {
+			case errors.Is(err, ErrCourseFull):
+				res.Result, res.Error = "error", "course is full"
+			case err != nil:
+				res.Result, res.Error = "error", "database error"
+			case already:
+				res.Result = "already_enrolled"
+			default:
+				res.Result = "enrolled"
+			}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// approveEnrollment makes sure the user has an approved enrollment on the
+// course, creating or upgrading it. It reports whether one already existed.
+func approveEnrollment(db *gorm.DB, userID uint, course models.Course, cohort string, actorID uint, reason string) (models.Enrollment, bool, error) {
+	var enrollment models.Enrollment
+	err := db.Where("user_id = ? AND course_id = ?", userID, course.ID).First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err := withSeatLock(db, course.ID, func(tx *gorm.DB, course models.Course) error {
+			if !HasFreeSeat(tx, course) {
+				return ErrCourseFull
+			}
+			enrollment = models.Enrollment{
+				UserID:    userID,
+				CourseID:  course.ID,
+				Status:    models.EnrollmentApproved,
+				ExpiresAt: accessExpiry(course),
+				Cohort:    cohort,
+			}
+			return tx.Create(&enrollment).Error
+		})
+		if err != nil {
+			return enrollment, false, err
+		}
+		recordTransition(db, enrollment.ID, "", enrollment.Status, actorID, reason)
+		return enrollment, false, nil
+	}
+	if err != nil {
+		return enrollment, false, err
+	}
+
+	already := enrollment.Status == models.EnrollmentApproved
+	if err := SetEnrollmentStatus(db, &enrollment, models.EnrollmentApproved, actorID, reason); err != nil {
+		return enrollment, false, err
+	}
+	if cohort != "" && enrollment.Cohort != cohort {
+		enrollment.Cohort = cohort
+		db.Model(&models.Enrollment{}).Where("id = ?", enrollment.ID).Update("cohort", cohort)
+	}
+	return enrollment, already, nil
+}
+
+// ClaimPendingInvitations turns invitations addressed to the user's email
+// into approved enrollments. Called on every login so invitations sent after
+// registration are picked up too.
+func ClaimPendingInvitations(db *gorm.DB, user models.User) {
+	if user.Email == "" {
+		return
+	}
+	var invitations []models.PendingInvitation
+	if err := db.Where("LOWER(email) = ? AND claimed_at IS NULL", strings.ToLower(user.Email)).
+		Find(&invitations).Error; err != nil {
+		log.Printf("ClaimPendingInvitations: %v", err)
+		return
+	}
+
+	for _, inv := range invitations {
+		var course models.Course
+		if err := db.First(&course, inv.CourseID).Error; err != nil {
+			continue
+		}
+		if _, _, err := approveEnrollment(db, user.ID, course, inv.Cohort, inv.InvitedByID, "invitation claimed"); err != nil {
+			// Курс заполнен — приглашение остаётся и будет забрано при следующем входе
+			log.Printf("ClaimPendingInvitations: course %d: %v", inv.CourseID, err)
+			continue
+		}
+		db.Model(&models.PendingInvitation{}).Where("id = ?", inv.ID).Updates(map[string]interface{}{
+			"claimed_at":    time.Now(),
+			"claimed_by_id": user.ID,
+		})
+		if user.Name == "" && inv.Name != "" {
+			user.Name = inv.Name
+			db.Model(&models.User{}).Where("id = ?", user.ID).Update("name", inv.Name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// CourseGrade returns the share of course quizzes the learner answered
+// correctly, in percent. Unanswered quizzes count as wrong; nil means the
+// course has no quizzes.
+func CourseGrade(db *gorm.DB, userID, courseID uint) *int {
+	quizzes := func() *gorm.DB {
+		return db.Model(&models.ContentBlock{}).
+			Joins("JOIN lessons ON lessons.id = content_blocks.lesson_id").
+			Joins("JOIN modules ON modules.id = lessons.module_id").
+			Where("modules.course_id = ? AND content_blocks.type = ? AND lessons.deleted_at IS NULL AND modules.deleted_at IS NULL",
+				courseID, "quiz")
+	}
+
+	var total int64
+	quizzes().Count(&total)
+	if total == 0 {
+		return nil
+	}
+
+	var correct int64
+	db.Model(&models.QuizAttempt{}).
+		Where("user_id = ? AND is_correct = ? AND block_id IN (?)", userID, true,
+			quizzes().Select("content_blocks.id")).
+		Count(&correct)
+
+	grade := int(correct * 100 / total)
+	return &grade
+}
+
+// CertificateTemplateFor returns the course's certificate template or the
+// default one.
+func CertificateTemplateFor(db *gorm.DB, courseID uint) models.CertificateTemplate {
+	var tpl models.CertificateTemplate
+	if err := db.Where("course_id = ?", courseID).First(&tpl).Error; err != nil {
+		return models.DefaultCertificateTemplate(courseID)
+	}
+	return tpl
+}
+
+// ErrAlreadyRevoked is returned when revoking a certificate twice.
+var ErrAlreadyRevoked = errors.New("certificate is already revoked")
+
+// RevokeCertificate withdraws a certificate on behalf of actorID. The record
+// and its code stay, so the verification page can explain why it is no
+// longer valid; completing the course again does not issue a new one.
+func RevokeCertificate(db *gorm.DB, cert *models.Certificate, actorID uint, reason string) error {
+	if cert.Revoked() {
+		return ErrAlreadyRevoked
+	}
+	now := time.Now()
+	if err := db.Model(&models.Certificate{}).Where("id = ?", cert.ID).Updates(map[string]interface{}{
+		"revoked_at":    &now,
+		"revoke_reason": reason,
+	}).Error; err != nil {
+		return err
+	}
+	cert.RevokedAt, cert.RevokeReason = &now, reason
+	recordCertificateEvent(db, cert.ID, models.CertificateRevoked, actorID, reason)
+	return nil
+}
+
+// ReissueCertificate brings a certificate up to date: the grade is
+// recomputed, the issue date reset, any revocation lifted and the payload
+// signed again. The code is kept so printed QR codes keep working; tokens
+// signed before the reissue are reported as superseded.
+func ReissueCertificate(db *gorm.DB, cert *models.Certificate, actorID uint, reason, issuer string) error {
+	cert.IssuedAt = time.Now()
+	cert.Grade = CourseGrade(db, cert.UserID, cert.CourseID)
+	if err := db.Model(&models.Certificate{}).Where("id = ?", cert.ID).Updates(map[string]interface{}{
+		"issued_at":     cert.IssuedAt,
+		"grade":         cert.Grade,
+		"revoked_at":    nil,
+		"revoke_reason": "",
+	}).Error; err != nil {
+		return err
+	}
+	cert.RevokedAt, cert.RevokeReason = nil, ""
+	recordCertificateEvent(db, cert.ID, models.CertificateReissued, actorID, reason)
+	return SignCertificate(db, cert, issuer)
+}
+
+// recordCertificateEvent appends a row to the certificate history. Like
+// recordTransition, failures are only logged.
+func recordCertificateEvent(db *gorm.DB, certID uint, action string, actorID uint, reason string) {
+	entry := models.CertificateEvent{
+		CertificateID: certID,
+		Action:        action,
+		ActorID:       actorID,
+		Reason:        reason,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("recordCertificateEvent: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrBadParent is returned when a reply points at a comment that does not
+// exist, was deleted or belongs to another lesson or course.
+var ErrBadParent = errors.New("parent comment not found")
+
+// CommentScope selects the comments of one lesson or one course.
+type CommentScope struct {
+	LessonID uint
+	CourseID uint
+}
+
+func (s CommentScope) apply(db *gorm.DB) *gorm.DB {
+	if s.LessonID > 0 {
+		return db.Where("comments.lesson_id = ?", s.LessonID)
+	}
+	return db.Where("comments.course_id = ? AND comments.lesson_id = 0", s.CourseID)
+}
+
+// CreateComment stores a comment; with parentID set it becomes a reply.
+// Replies nested deeper than MaxCommentDepth are attached to the parent's
+// parent so threads stay readable.
+func CreateComment(db *gorm.DB, c *models.Comment, parentID uint) error {
+	if parentID > 0 {
+		var parent models.Comment
+		scope := CommentScope{LessonID: c.LessonID, CourseID: c.CourseID}
+		if err := scope.apply(db).Where("status = ?", models.ContentPublished).First(&parent, parentID).Error; err != nil {
+			return ErrBadParent
+		}
+		if parent.Depth >= models.MaxCommentDepth && parent.ParentID != nil {
+			// Слишком глубоко — отвечаем родителю родителя; он, как и сам
+			// родитель, должен быть виден, иначе ответ повиснет под удалённым
+			var grandparent models.Comment
+			if err := scope.apply(db).Where("status = ?", models.ContentPublished).First(&grandparent, *parent.ParentID).Error; err != nil {
+				return ErrBadParent
+			}
+			parent = grandparent
+		}
+		root := parent.ID
+		if parent.RootID != nil {
+			root = *parent.RootID
+		}
+		c.ParentID, c.RootID, c.Depth = &parent.ID, &root, parent.Depth+1
+		c.NotifyReply = true
+	}
+	return db.Create(c).Error
+}
+
+// visibleReply matches replies (aliased r) shown to everyone.
+const visibleReply = "r.deleted_at IS NULL AND r.status = 'published'"
+
+// CommentThreads returns a page of top-level comments, each with its whole
+// thread of replies (oldest first). sort is "newest", "oldest" or
+// "popular" (most replies). Deleted, hidden and pre-moderated comments stay
+// in the thread as tombstones while they still have visible replies.
+func CommentThreads(db *gorm.DB, scope CommentScope, sort string, page, limit int) ([]models.Comment, int64, error) {
+	roots := func() *gorm.DB {
+		return scope.apply(db.Unscoped().Model(&models.Comment{})).
+			Where("comments.parent_id IS NULL").
+			Where("((comments.deleted_at IS NULL AND comments.status = 'published') OR EXISTS (SELECT 1 FROM comments r WHERE r.root_id = comments.id AND " + visibleReply + "))")
+	}
+
+	var total int64
+	if err := roots().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	q := roots().Preload("User").Preload("Mentions.User")
+	switch sort {
+	case "oldest":
+		q = q.Order("comments.created_at asc")
+	case "popular":
+		q = q.Order("(SELECT count(*) FROM comments r WHERE r.root_id = comments.id AND " + visibleReply + ") desc").
+			Order("comments.created_at desc")
+	default:
+		q = q.Order("comments.created_at desc")
+	}
+	var top []models.Comment
+	if err := q.Limit(limit).Offset((page - 1) * limit).Find(&top).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(top) == 0 {
+		return top, total, nil
+	}
+
+	ids := make([]uint, len(top))
+	for i, c := range top {
+		ids[i] = c.ID
+	}
+	var replies []models.Comment
+	if err := db.Unscoped().Preload("User").Preload("Mentions.User").Where("root_id IN ?", ids).
+		Order("created_at asc").Find(&replies).Error; err != nil {
+		return nil, 0, err
+	}
+
+	children := make(map[uint][]models.Comment)
+	for _, r := range replies {
+		children[*r.ParentID] = append(children[*r.ParentID], r)
+	}
+	out := make([]models.Comment, 0, len(top))
+	for _, c := range top {
+		if c, ok := buildThread(c, children); ok {
+			out = append(out, c)
+		}
+	}
+	return out, total, nil
+}
+
+// buildThread attaches replies recursively and reports whether the comment
+// should be shown: removed comments are kept only as parents of live ones.
+func buildThread(c models.Comment, children map[uint][]models.Comment) (models.Comment, bool) {
+	for _, child := range children[c.ID] {
+		if child, ok := buildThread(child, children); ok {
+			c.Replies = append(c.Replies, child)
+			if !child.Deleted {
+				c.ReplyCount++
+			}
+		}
+	}
+	if c.DeletedAt.Valid || c.Status != models.ContentPublished {
+		if len(c.Replies) == 0 {
+			return c, false
+		}
+		c.Deleted, c.Content, c.User, c.Mentions = true, "", models.User{}, nil
+	}
+	return c, true
+}
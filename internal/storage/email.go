@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrBadUnsubscribeToken is returned for an unknown unsubscribe link.
+var ErrBadUnsubscribeToken = errors.New("invalid unsubscribe link")
+
+// EmailPreferences returns the user's e-mail settings, creating the default
+// (everything on) with a fresh unsubscribe token on first use.
+func EmailPreferences(db *gorm.DB, userID uint) (models.EmailPreference, error) {
+	var p models.EmailPreference
+	err := db.Where("user_id = ?", userID).First(&p).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return p, err
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return p, err
+	}
+	p = models.EmailPreference{UserID: userID, Token: hex.EncodeToString(b)}
+	// Параллельный запрос мог уже создать запись
+	if err := db.Where("user_id = ?", userID).FirstOrCreate(&p).Error; err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// SetEmailPreferences stores which letter categories the user receives.
+// Only the categories present in allowed change.
+func SetEmailPreferences(db *gorm.DB, userID uint, allowed map[string]*bool) (models.EmailPreference, error) {
+	p, err := EmailPreferences(db, userID)
+	if err != nil {
+		return p, err
+	}
+	for category, mute := range map[string]*bool{
+		models.EmailEnrollment:   &p.MuteEnrollment,
+		models.EmailCourseReview: &p.MuteCourseReview,
+		models.EmailCertificate:  &p.MuteCertificate,
+	} {
+		if on := allowed[category]; on != nil {
+			*mute = !*on
+		}
+	}
+	return p, db.Save(&p).Error
+}
+
+// EmailPreferencesByToken finds the preferences of an unsubscribe token
+// without changing them.
+func EmailPreferencesByToken(db *gorm.DB, token string) (models.EmailPreference, error) {
+	var p models.EmailPreference
+	if token == "" || db.Where("token = ?", token).First(&p).Error != nil {
+		return p, ErrBadUnsubscribeToken
+	}
+	return p, nil
+}
+
+// UnsubscribeEmail turns off one category, or all of them for "" / "all",
+// for the owner of the unsubscribe token.
+func UnsubscribeEmail(db *gorm.DB, token, category string) (models.EmailPreference, error) {
+	p, err := EmailPreferencesByToken(db, token)
+	if err != nil {
+		return p, err
+	}
+	all := category == "" || category == "all"
+	if all || category == models.EmailEnrollment {
+		p.MuteEnrollment = true
+	}
+	if all || category == models.EmailCourseReview {
+		p.MuteCourseReview = true
+	}
+	if all || category == models.EmailCertificate {
+		p.MuteCertificate = true
+	}
+	return p, db.Save(&p).Error
+}
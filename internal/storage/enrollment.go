@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCourseFull is returned when an enrollment cannot be approved because
+// every seat of the course is taken.
+var ErrCourseFull = errors.New("course has no free seats")
+
+// TakenSeats counts approved, not yet expired enrollments of a course.
+func TakenSeats(db *gorm.DB, courseID uint) int64 {
+	var n int64
+	db.Model(&models.Enrollment{}).
+		Where("course_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)",
+			courseID, models.EnrollmentApproved, time.Now()).
+		Count(&n)
+	return n
+}
+
+// HasFreeSeat reports whether one more learner can be approved.
+func HasFreeSeat(db *gorm.DB, course models.Course) bool {
+	return course.MaxSeats <= 0 || TakenSeats(db, course.ID) < int64(course.MaxSeats)
+}
+
+// withSeatLock runs fn in a transaction holding the course row lock
+// (SELECT ... FOR UPDATE), so that counting free seats and taking one is
+// atomic across concurrent requests and approvals.
+func withSeatLock(db *gorm.DB, courseID uint, fn func(tx *gorm.DB, course models.Course) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var course models.Course
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&course, courseID).Error; err != nil {
+			return err
+		}
+		return fn(tx, course)
+	})
+}
+
+// HasActiveEnrollment reports whether the user has an approved, non-expired
+// enrollment for the course.
+func HasActiveEnrollment(db *gorm.DB, userID, courseID uint) bool {
+	var n int64
+	db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)",
+			userID, courseID, models.EnrollmentApproved, time.Now()).
+		Count(&n)
+	return n > 0
+}
+
+// AutoApproves reports whether the course rules admit a learner with the
+// given email without manual review.
+func AutoApproves(course models.Course, email string) bool {
+	switch course.AutoApprove {
+	case "all":
+		return true
+	case "domain":
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			return false
+		}
+		domain := strings.ToLower(email[at+1:])
+		for _, d := range strings.Split(course.AutoApproveDomains, ",") {
+			d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+			if d != "" && d == domain {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// accessExpiry returns the access end date for an enrollment approved now.
+func accessExpiry(course models.Course) *time.Time {
+	if course.AccessDays <= 0 {
+		return nil
+	}
+	t := time.Now().AddDate(0, 0, course.AccessDays)
+	return &t
+}
+
+// initialStatus applies the course rules to a learner asking for access:
+// learners matching auto-approval are approved while seats remain and
+// waitlisted once the course is full; everyone else waits for review.
+func initialStatus(db *gorm.DB, user models.User, course models.Course) string {
+	if !AutoApproves(course, user.Email) {
+		return models.EnrollmentPending
+	}
+	if HasFreeSeat(db, course) {
+		return models.EnrollmentApproved
+	}
+	return models.EnrollmentWaitlisted
+}
+
+// CreateEnrollment creates a new enrollment with the status chosen by the
+// course rules and records it in the enrollment history.
+func CreateEnrollment(db *gorm.DB, user models.User, course models.Course) (models.Enrollment, error) {
+	var enrollment models.Enrollment
+	err := withSeatLock(db, course.ID, func(tx *gorm.DB, course models.Course) error {
+		enrollment = models.Enrollment{
+			UserID:   user.ID,
+			CourseID: course.ID,
+			Status:   initialStatus(tx, user, course),
+		}
+		if enrollment.Status == models.EnrollmentApproved {
+			enrollment.ExpiresAt = accessExpiry(course)
+		}
+		return tx.Create(&enrollment).Error
+	})
+	if err != nil {
+		return enrollment, err
+	}
+	recordTransition(db, enrollment.ID, "", enrollment.Status, user.ID, "request")
+	return enrollment, nil
+}
+
+// RerequestEnrollment lets a learner apply again after leaving, being
+// rejected or running out of access. The course rules are applied as for a
+// new request.
+func RerequestEnrollment(db *gorm.DB, enrollment *models.Enrollment, user models.User, reason string) error {
+	var course models.Course
+	if err := db.First(&course, enrollment.CourseID).Error; err != nil {
+		return err
+	}
+	if reason == "" {
+		reason = "re-request"
+	}
+	return SetEnrollmentStatus(db, enrollment, initialStatus(db, user, course), user.ID, reason)
+}
+
+// SetEnrollmentStatus moves an enrollment to a new status on behalf of
+// actorID (0 — the system) and appends the transition to the history.
+// Approving checks the seat limit and starts the access period; when an
+// approved learner leaves, the waitlist is promoted into the freed seat.
+// The current status is re-read under the lock, so a concurrent request
+// that already made the same change turns this one into a no-op.
+func SetEnrollmentStatus(db *gorm.DB, enrollment *models.Enrollment, status string, actorID uint, reason string) error {
+	var course models.Course
+	var current models.Enrollment
+	err := withSeatLock(db, enrollment.CourseID, func(tx *gorm.DB, locked models.Course) error {
+		course = locked
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status", "expires_at").First(&current, enrollment.ID).Error; err != nil {
+			return err
+		}
+		if current.Status == status {
+			return nil
+		}
+		updates := map[string]interface{}{"status": status}
+		if status == models.EnrollmentApproved {
+			if !HasFreeSeat(tx, course) {
+				return ErrCourseFull
+			}
+			current.ExpiresAt = accessExpiry(course)
+			updates["expires_at"] = current.ExpiresAt
+		}
+		return tx.Model(&models.Enrollment{}).Where("id = ?", enrollment.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	from := current.Status
+	enrollment.Status = status
+	enrollment.ExpiresAt = current.ExpiresAt
+	if from == status {
+		return nil
+	}
+	recordTransition(db, enrollment.ID, from, status, actorID, reason)
+
+	if from == models.EnrollmentApproved {
+		PromoteWaitlist(db, course)
+	}
+	return nil
+}
+
+// PromoteWaitlist approves waitlisted learners in arrival order while the
+// course has free seats.
+func PromoteWaitlist(db *gorm.DB, course models.Course) {
+	for {
+		var next models.Enrollment
+		err := withSeatLock(db, course.ID, func(tx *gorm.DB, course models.Course) error {
+			if !HasFreeSeat(tx, course) {
+				return ErrCourseFull
+			}
+			if err := tx.Where("course_id = ? AND status = ?", course.ID, models.EnrollmentWaitlisted).
+				Order("created_at asc").First(&next).Error; err != nil {
+				return err
+			}
+			return tx.Model(&next).Updates(map[string]interface{}{
+				"status":     models.EnrollmentApproved,
+				"expires_at": accessExpiry(course),
+			}).Error
+		})
+		if errors.Is(err, ErrCourseFull) || errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		if err != nil {
+			log.Printf("PromoteWaitlist: %v", err)
+			return
+		}
+		recordTransition(db, next.ID, models.EnrollmentWaitlisted, models.EnrollmentApproved, 0, "seat freed")
+	}
+}
+
+// recordTransition appends a row to the enrollment history, notifies the
+// learner and queues the enrollment webhooks. Failures are logged, never
+// returned: history must not block the status change itself.
+func recordTransition(db *gorm.DB, enrollmentID uint, from, to string, actorID uint, reason string) {
+	entry := models.EnrollmentHistory{
+		EnrollmentID: enrollmentID,
+		FromStatus:   from,
+		ToStatus:     to,
+		ActorID:      actorID,
+		Reason:       reason,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("recordTransition: %v", err)
+	}
+	notifyEnrollmentStatus(db, enrollmentID, to, actorID)
+
+	if from == "" {
+		dispatchEnrollmentWebhook(db, models.EventEnrollmentCreated, enrollmentID)
+	}
+	if to == models.EnrollmentApproved {
+		dispatchEnrollmentWebhook(db, models.EventEnrollmentApproved, enrollmentID)
+	}
+}
+
+// ExpireEnrollments marks approved enrollments past their access date as
+// expired and hands the freed seats to waitlisted learners.
+func ExpireEnrollments(db *gorm.DB) {
+	var expired []models.Enrollment
+	if err := db.Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?",
+		models.EnrollmentApproved, time.Now()).Find(&expired).Error; err != nil {
+		log.Printf("ExpireEnrollments: %v", err)
+		return
+	}
+
+	courseIDs := make(map[uint]bool)
+	for _, e := range expired {
+		// Условие по статусу: пока шёл обход, заявку могли изменить вручную
+		res := db.Model(&models.Enrollment{}).Where("id = ? AND status = ?", e.ID, models.EnrollmentApproved).
+			Update("status", models.EnrollmentExpired)
+		if res.Error != nil {
+			log.Printf("ExpireEnrollments: %v", res.Error)
+			continue
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		recordTransition(db, e.ID, models.EnrollmentApproved, models.EnrollmentExpired, 0, "access period ended")
+		courseIDs[e.CourseID] = true
+	}
+
+	for id := range courseIDs {
+		var course models.Course
+		if db.First(&course, id).Error == nil {
+			PromoteWaitlist(db, course)
+		}
+	}
+}
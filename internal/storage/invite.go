@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInviteNotFound  = errors.New("invite not found")
+	ErrInviteExpired   = errors.New("invite expired or revoked")
+	ErrInviteExhausted = errors.New("invite usage limit reached")
+	ErrInviteRedeemed  = errors.New("invite already used")
+)
+
+// codeAlphabet omits look-alike characters (0/O, 1/I/L) so codes can be
+// dictated or copied from paper.
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// NewInviteToken returns a random token for invite links.
+func NewInviteToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewAccessCode returns a human-friendly code like "K7QM-3XPA".
+func NewAccessCode() (string, error) {
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			sb.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// NormalizeAccessCode makes user input comparable to stored codes.
+func NormalizeAccessCode(code string) string {
+	code = strings.ToUpper(strings.Join(strings.Fields(code), ""))
+	if len(code) == 8 && !strings.Contains(code, "-") {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}
+
+// RedeemInvite enrolls the user through an invite link token or access code.
+// The enrollment is approved immediately (seat limits still apply) and the
+// redemption is recorded. Redeeming twice is a no-op for the same user: the
+// current enrollment is returned as is, so a learner the author rejected or
+// removed is not approved again by the same invite. A learner who is
+// already approved does not use up the invite either.
+func RedeemInvite(db *gorm.DB, token string, user models.User) (models.Enrollment, models.CourseInvite, error) {
+	var enrollment models.Enrollment
+	var invite models.CourseInvite
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token = ?", token).First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInviteNotFound
+			}
+			return err
+		}
+		if invite.RevokedAt != nil || (invite.ExpiresAt != nil && invite.ExpiresAt.Before(time.Now())) {
+			return ErrInviteExpired
+		}
+
+		var redeemed int64
+		tx.Model(&models.InviteRedemption{}).
+			Where("invite_id = ? AND user_id = ?", invite.ID, user.ID).Count(&redeemed)
+		if redeemed > 0 {
+			err := tx.Where("user_id = ? AND course_id = ?", user.ID, invite.CourseID).First(&enrollment).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInviteRedeemed
+			}
+			return err
+		}
+		if err := tx.Where("user_id = ? AND course_id = ? AND status = ?", user.ID, invite.CourseID, models.EnrollmentApproved).
+			First(&enrollment).Error; err == nil {
+			return nil
+		}
+		if invite.MaxUses > 0 && invite.UsedCount >= invite.MaxUses {
+			return ErrInviteExhausted
+		}
+
+		var course models.Course
+		if err := tx.First(&course, invite.CourseID).Error; err != nil {
+			return err
+		}
+		var err error
+		if enrollment, _, err = approveEnrollment(tx, user.ID, course, invite.Cohort, user.ID, fmt.Sprintf("invite #%d", invite.ID)); err != nil {
+			return err
+		}
+		if err := tx.Create(&models.InviteRedemption{
+			InviteID:     invite.ID,
+			UserID:       user.ID,
+			EnrollmentID: enrollment.ID,
+		}).Error; err != nil {
+			return err
+		}
+		invite.UsedCount++
+		return tx.Model(&models.CourseInvite{}).Where("id = ?", invite.ID).
+			Update("used_count", gorm.Expr("used_count + 1")).Error
+	})
+
+	return enrollment, invite, err
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultJobAttempts is how many times a job runs before it is failed.
+const DefaultJobAttempts = 5
+
+// ErrJobNotFailed is returned when retrying a job that has not failed.
+var ErrJobNotFailed = errors.New("only failed jobs can be retried")
+
+// JobOptions tune EnqueueJob. The zero value runs the job as soon as
+// possible with DefaultJobAttempts.
+type JobOptions struct {
+	RunAt       time.Time
+	MaxAttempts int
+	UniqueKey   string // задача с таким ключом уже есть (пока не удалена очисткой) — новая не ставится
+}
+
+// EnqueueJob stores a job for the worker pool. Failures are logged, never
+// returned, like other side effects of a request.
+func EnqueueJob(db *gorm.DB, kind string, payload interface{}, opts JobOptions) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("EnqueueJob %s: %v", kind, err)
+		return
+	}
+	job := models.Job{
+		Kind:        kind,
+		Payload:     data,
+		Status:      models.JobQueued,
+		RunAt:       opts.RunAt,
+		MaxAttempts: opts.MaxAttempts,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = DefaultJobAttempts
+	}
+	if opts.UniqueKey != "" {
+		job.UniqueKey = &opts.UniqueKey
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&job).Error; err != nil {
+		log.Printf("EnqueueJob %s: %v", kind, err)
+	}
+}
+
+// ClaimJob takes the next due job and marks it running. ok is false when
+// the queue is empty.
+func ClaimJob(db *gorm.DB) (job models.Job, ok bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", models.JobQueued, time.Now()).
+			Order("run_at").Order("id").Limit(1).Find(&job)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		// Postgres хранит микросекунды — иначе FinishJob не найдёт свой locked_at
+		now := time.Now().Truncate(time.Microsecond)
+		job.Status, job.LockedAt = models.JobRunning, &now
+		job.Attempts++
+		ok = true
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"locked_at": now,
+			"attempts":  job.Attempts,
+		}).Error
+	})
+	return job, ok, err
+}
+
+// FinishJob records the outcome of a run. A failed run is retried after
+// backoff until MaxAttempts is reached, then the job is marked failed.
+// Nothing is written when the job was requeued and claimed again since
+// this run started: the newer run owns its state.
+func FinishJob(db *gorm.DB, job *models.Job, runErr error, backoff time.Duration) {
+	now := time.Now()
+	updates := map[string]interface{}{"locked_at": nil}
+	switch {
+	case runErr == nil:
+		updates["status"], updates["finished_at"], updates["last_error"] = models.JobDone, now, ""
+	case job.Attempts < job.MaxAttempts:
+		updates["status"], updates["run_at"], updates["last_error"] = models.JobQueued, now.Add(backoff), runErr.Error()
+	default:
+		updates["status"], updates["finished_at"], updates["last_error"] = models.JobFailed, now, runErr.Error()
+	}
+	res := db.Model(job).Where("status = ? AND locked_at = ?", models.JobRunning, job.LockedAt).Updates(updates)
+	if res.Error != nil {
+		log.Printf("FinishJob #%d: %v", job.ID, res.Error)
+	} else if res.RowsAffected == 0 {
+		log.Printf("FinishJob #%d: run superseded, result dropped", job.ID)
+	}
+}
+
+// RequeueStaleJobs returns jobs stuck in "running" longer than timeout
+// (their worker died) to the queue. Jobs that have used up their attempts
+// are marked failed instead.
+func RequeueStaleJobs(db *gorm.DB, timeout time.Duration) {
+	now := time.Now()
+	stale := func() *gorm.DB {
+		return db.Model(&models.Job{}).Where("status = ? AND locked_at < ?", models.JobRunning, now.Add(-timeout))
+	}
+	if err := stale().Where("attempts >= max_attempts").Updates(map[string]interface{}{
+		"status":      models.JobFailed,
+		"locked_at":   nil,
+		"finished_at": now,
+		"last_error":  "worker timed out",
+	}).Error; err != nil {
+		log.Printf("RequeueStaleJobs: %v", err)
+	}
+	// исчерпавшие попытки уже помечены failed и сюда не попадут
+	if err := stale().Updates(map[string]interface{}{
+		"status":     models.JobQueued,
+		"locked_at":  nil,
+		"last_error": "worker timed out",
+	}).Error; err != nil {
+		log.Printf("RequeueStaleJobs: %v", err)
+	}
+}
+
+// RetryJob puts a failed job back into the queue with a fresh set of
+// attempts.
+func RetryJob(db *gorm.DB, id uint) (models.Job, error) {
+	var job models.Job
+	if err := db.First(&job, id).Error; err != nil {
+		return job, err
+	}
+	if job.Status != models.JobFailed {
+		return job, ErrJobNotFailed
+	}
+	err := db.Model(&job).Updates(map[string]interface{}{
+		"status":      models.JobQueued,
+		"attempts":    0,
+		"run_at":      time.Now(),
+		"finished_at": nil,
+	}).Error
+	return job, err
+}
+
+// JobStats counts jobs by status.
+func JobStats(db *gorm.DB) map[string]int64 {
+	stats := map[string]int64{models.JobQueued: 0, models.JobRunning: 0, models.JobDone: 0, models.JobFailed: 0}
+	var rows []struct {
+		Status string
+		N      int64
+	}
+	db.Model(&models.Job{}).Select("status, count(*) AS n").Group("status").Scan(&rows)
+	for _, row := range rows {
+		stats[row.Status] = row.N
+	}
+	return stats
+}
+
+// CleanupJobs deletes finished jobs older than age; failed jobs are kept
+// for inspection.
+func CleanupJobs(db *gorm.DB, age time.Duration) error {
+	return db.Where("status = ? AND finished_at < ?", models.JobDone, time.Now().Add(-age)).
+		Delete(&models.Job{}).Error
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxMentions caps how many users one comment may notify.
+const maxMentions = 10
+
+// mentionRe matches "@handle" not preceded by a word character, so e-mail
+// addresses are not taken for mentions.
+var mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@([\p{L}\p{N}_.\-]+)`)
+
+var publicIDRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ParseMentionHandles returns the distinct @handles in text, in order.
+func ParseMentionHandles(text string) []string {
+	var handles []string
+	seen := make(map[string]bool)
+	for _, m := range mentionRe.FindAllStringSubmatch(text, -1) {
+		h := strings.TrimRight(m[1], ".-")
+		key := strings.ToLower(h)
+		if h == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		handles = append(handles, h)
+		if len(handles) == maxMentions {
+			break
+		}
+	}
+	return handles
+}
+
+// ResolveMentions maps handles to users. A handle is either a public ID or
+// a name with spaces written as underscores ("@Aida_Asanova"); names are
+// looked up among the course's author, assistants, learners and
+// commenters, and skipped when ambiguous.
+func ResolveMentions(db *gorm.DB, handles []string, courseID uint) []models.CommentMention {
+	var out []models.CommentMention
+	seen := make(map[uint]bool)
+	add := func(handle string, u models.User) {
+		if !seen[u.ID] {
+			seen[u.ID] = true
+			out = append(out, models.CommentMention{UserID: u.ID, Handle: handle, User: u})
+		}
+	}
+
+	for _, h := range handles {
+		if publicIDRe.MatchString(h) {
+			var u models.User
+			if db.Where("public_id = ?", strings.ToLower(h)).First(&u).Error == nil {
+				add(h, u)
+			}
+			continue
+		}
+		if courseID == 0 {
+			continue
+		}
+		var users []models.User
+		db.Where("LOWER(REPLACE(name, ' ', '_')) = ?", strings.ToLower(h)).
+			Where(`id IN (SELECT author_id FROM courses WHERE id = @course)
+				OR id IN (SELECT user_id FROM course_assistants WHERE course_id = @course)
+				OR id IN (SELECT user_id FROM enrollments WHERE course_id = @course AND deleted_at IS NULL)
+				OR id IN (SELECT c.user_id FROM comments c LEFT JOIN lessons l ON l.id = c.lesson_id
+					LEFT JOIN modules m ON m.id = l.module_id
+					WHERE c.course_id = @course OR m.course_id = @course)`,
+				map[string]interface{}{"course": courseID}).
+			Limit(2).Find(&users)
+		if len(users) == 1 {
+			add(h, users[0])
+		}
+	}
+	return out
+}
+
+// SaveMentions replaces the comment's mentions. Users mentioned for the
+// first time are owed a notification (see NotifyCommentPublished); users
+// already mentioned keep their state, so edits do not notify twice.
+func SaveMentions(db *gorm.DB, c *models.Comment, mentions []models.CommentMention) {
+	var before []models.CommentMention
+	db.Where("comment_id = ?", c.ID).Find(&before)
+	pending := make(map[uint]bool, len(before))
+	for _, m := range before {
+		pending[m.UserID] = m.NotifyPending
+	}
+
+	db.Where("comment_id = ?", c.ID).Delete(&models.CommentMention{})
+	for i := range mentions {
+		mentions[i].CommentID = c.ID
+		was, had := pending[mentions[i].UserID]
+		mentions[i].NotifyPending = !had || was
+	}
+	if len(mentions) > 0 {
+		db.Omit("User").Create(&mentions)
+	}
+	c.Mentions = mentions
+}
+
+// NotifyCommentPublished sends the notifications a published comment still
+// owes: to mentioned users not notified yet and, once, to the author of the
+// parent comment. A pre-moderated comment owes them until a moderator
+// approves it. Each is claimed before sending, so none goes out twice.
+func NotifyCommentPublished(db *gorm.DB, c models.Comment) {
+	if c.Status != models.ContentPublished {
+		return
+	}
+
+	var mentioned []uint
+	db.Model(&models.CommentMention{}).Where("comment_id = ? AND notify_pending", c.ID).Pluck("user_id", &mentioned)
+	if len(mentioned) > 0 {
+		db.Model(&models.CommentMention{}).Where("comment_id = ? AND user_id IN ?", c.ID, mentioned).
+			Update("notify_pending", false)
+	}
+	var replyTo uint
+	if c.ParentID != nil {
+		res := db.Model(&models.Comment{}).Where("id = ? AND notify_reply", c.ID).Update("notify_reply", false)
+		if res.Error == nil && res.RowsAffected > 0 {
+			var parent models.Comment
+			if db.Unscoped().Select("id, user_id").First(&parent, *c.ParentID).Error == nil {
+				replyTo = parent.UserID
+			}
+		}
+	}
+	if len(mentioned) == 0 && replyTo == 0 {
+		return
+	}
+
+	courseID := c.CourseID
+	var subject, link string
+	if c.LessonID > 0 {
+		courseID, _ = LessonCourseID(db, c.LessonID)
+		var lesson models.Lesson
+		db.Select("id, title").First(&lesson, c.LessonID)
+		subject = lesson.Title
+		link = fmt.Sprintf("/course/%d/lesson/%d#comment-%d", courseID, c.LessonID, c.ID)
+	} else {
+		var course models.Course
+		db.Select("id, title").First(&course, courseID)
+		subject = course.Title
+		link = fmt.Sprintf("/course/%d/learn#comment-%d", courseID, c.ID)
+	}
+
+	n := models.Notification{ActorID: c.UserID, Subject: subject, Link: link}
+	for _, userID := range mentioned {
+		n.UserID, n.Kind = userID, models.NotifyMention
+		Notify(db, n)
+		if userID == replyTo {
+			replyTo = 0 // уже получил уведомление об упоминании
+		}
+	}
+	if replyTo != 0 {
+		detail := []rune(c.Content)
+		if len(detail) > 120 {
+			detail = append(detail[:119], '…')
+		}
+		n.UserID, n.Kind, n.Detail = replyTo, models.NotifyCommentReply, string(detail)
+		Notify(db, n)
+	}
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAlreadyReported = errors.New("you have already reported this")
+	ErrUnknownTarget   = errors.New("unknown moderation target")
+)
+
+// ─────────────────────────────────────────────
+// Banned words (pre-moderation)
+// ─────────────────────────────────────────────
+
+// FindBannedWord returns the first banned word of the given languages that
+// occurs in text. Matching is by whole word, case-insensitive; list entries
+// ending in "*" match any word with that prefix.
+func FindBannedWord(db *gorm.DB, text string, langs ...string) (string, bool) {
+	var words []string
+	db.Model(&models.BannedWord{}).Where("lang IN ?", langs).Pluck("word", &words)
+	if len(words) == 0 {
+		return "", false
+	}
+
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		prefix, isPrefix := strings.CutSuffix(strings.ToLower(w), "*")
+		for _, tok := range tokens {
+			if tok == prefix || (isPrefix && strings.HasPrefix(tok, prefix)) {
+				return w, true
+			}
+		}
+	}
+	return "", false
+}
+
+// InitialContentStatus decides whether new user content is published right
+// away or waits in the moderation queue. The banned-word lists of the
+// author's language and of the course language are checked.
+func InitialContentStatus(db *gorm.DB, text string, userID, courseID uint) string {
+	langs := []string{}
+	var user models.User
+	if db.Select("language").First(&user, userID).Error == nil && user.Language != "" {
+		langs = append(langs, user.Language)
+	}
+	var course models.Course
+	if courseID > 0 && db.Select("language").First(&course, courseID).Error == nil && course.Language != "" {
+		langs = append(langs, course.Language)
+	}
+	if len(langs) == 0 {
+		langs = append(langs, "ru")
+	}
+	if _, found := FindBannedWord(db, text, langs...); found {
+		return models.ContentPending
+	}
+	return models.ContentPublished
+}
+
+// SetBannedWords replaces the banned-word list of a language.
+func SetBannedWords(db *gorm.DB, lang string, words []string) error {
+	seen := make(map[string]bool)
+	list := make([]models.BannedWord, 0, len(words))
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || w == "*" || seen[w] || len(w) > 100 {
+			continue
+		}
+		seen[w] = true
+		list = append(list, models.BannedWord{Lang: lang, Word: w})
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lang = ?", lang).Delete(&models.BannedWord{}).Error; err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		return tx.Create(&list).Error
+	})
+}
+
+// ─────────────────────────────────────────────
+// Mute / ban
+// ─────────────────────────────────────────────
+
+// ActiveRestriction returns the user's current mute or ban, if any.
+func ActiveRestriction(db *gorm.DB, userID uint) (models.UserRestriction, bool) {
+	var rs models.UserRestriction
+	err := db.Where("user_id = ? AND (until IS NULL OR until > ?)", userID, time.Now()).First(&rs).Error
+	return rs, err == nil
+}
+
+// RestrictUser mutes the user for the given number of days or, with
+// days <= 0, bans them until lifted.
+func RestrictUser(db *gorm.DB, userID, byID uint, days int, reason string) (models.UserRestriction, error) {
+	rs := models.UserRestriction{UserID: userID}
+	db.Where("user_id = ?", userID).First(&rs)
+
+	rs.Kind, rs.Until, rs.Reason, rs.ByID = models.RestrictionBan, nil, reason, byID
+	if days > 0 {
+		until := time.Now().AddDate(0, 0, days)
+		rs.Kind, rs.Until = models.RestrictionMute, &until
+	}
+	return rs, db.Save(&rs).Error
+}
+
+// LiftRestriction removes the user's mute or ban.
+func LiftRestriction(db *gorm.DB, userID uint) error {
+	return db.Where("user_id = ?", userID).Delete(&models.UserRestriction{}).Error
+}
+
+// ─────────────────────────────────────────────
+// Reports and the moderation queue
+// ─────────────────────────────────────────────
+
+func moderationModel(targetType string) (interface{}, error) {
+	switch targetType {
+	case "comment":
+		return &models.Comment{}, nil
+	case "review":
+		return &models.Review{}, nil
+	}
+	return nil, ErrUnknownTarget
+}
+
+// ReportContent files a user's complaint about a comment or review.
+func ReportContent(db *gorm.DB, targetType string, targetID, reporterID uint, reason string) error {
+	model, err := moderationModel(targetType)
+	if err != nil {
+		return err
+	}
+	if err := db.First(model, targetID).Error; err != nil {
+		return err
+	}
+
+	var n int64
+	db.Model(&models.Report{}).Where("target_type = ? AND target_id = ? AND reporter_id = ?",
+		targetType, targetID, reporterID).Count(&n)
+	if n > 0 {
+		return ErrAlreadyReported
+	}
+	return db.Create(&models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Status:     models.ReportOpen,
+	}).Error
+}
+
+// ModerationItem is a comment or review waiting for a moderator.
+type ModerationItem struct {
+	TargetType  string    `json:"target_type"`
+	TargetID    uint      `json:"target_id"`
+	UserID      uint      `json:"user_id"`
+	CourseID    uint      `json:"course_id"`
+	LessonID    uint      `json:"lesson_id"`
+	Content     string    `json:"content"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	OpenReports int       `json:"open_reports"`
+
+	User    models.User     `json:"user" gorm:"-"`
+	Reports []models.Report `json:"reports" gorm:"-"`
+	Matched string          `json:"matched_word,omitempty" gorm:"-"` // сработавшее запрещённое слово
+}
+
+const moderationQueueSQL = `
+SELECT * FROM (
+	SELECT 'comment' AS target_type, c.id AS target_id, c.user_id, c.course_id, c.lesson_id,
+		c.content, c.status, c.created_at,
+		(SELECT count(*) FROM reports r WHERE r.target_type = 'comment' AND r.target_id = c.id AND r.status = 'open') AS open_reports
+	FROM comments c WHERE c.deleted_at IS NULL
+	UNION ALL
+	SELECT 'review', v.id, v.user_id, v.course_id, 0, v.content, v.status, v.created_at,
+		(SELECT count(*) FROM reports r WHERE r.target_type = 'review' AND r.target_id = v.id AND r.status = 'open')
+	FROM reviews v WHERE v.deleted_at IS NULL
+) q`
+
+// ModerationQueue lists content needing attention. view "open" (default)
+// returns pre-moderated and reported items, "hidden" the hidden ones.
+func ModerationQueue(db *gorm.DB, view string, page, limit int) ([]ModerationItem, int64, error) {
+	where := " WHERE q.status = 'pending' OR q.open_reports > 0"
+	if view == "hidden" {
+		where = " WHERE q.status = 'hidden'"
+	}
+
+	var total int64
+	if err := db.Raw("SELECT count(*) FROM (" + moderationQueueSQL + where + ") t").Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var items []ModerationItem
+	if err := db.Raw(moderationQueueSQL+where+" ORDER BY q.open_reports DESC, q.created_at DESC LIMIT ? OFFSET ?",
+		limit, (page-1)*limit).Scan(&items).Error; err != nil {
+		return nil, 0, err
+	}
+
+	for i := range items {
+		it := &items[i]
+		db.First(&it.User, it.UserID)
+		db.Preload("Reporter").Where("target_type = ? AND target_id = ? AND status = ?",
+			it.TargetType, it.TargetID, models.ReportOpen).Order("created_at asc").Find(&it.Reports)
+		if it.Status == models.ContentPending {
+			langs := []string{it.User.Language}
+			var course models.Course
+			if db.Select("language").First(&course, it.CourseID).Error == nil {
+				langs = append(langs, course.Language)
+			}
+			it.Matched, _ = FindBannedWord(db, it.Content, langs...)
+		}
+	}
+	return items, total, nil
+}
+
+// ModerateContent applies a moderator decision: "hide", "restore" (also
+// approves pre-moderated content) or "delete". Open reports are closed.
+func ModerateContent(db *gorm.DB, targetType string, targetID, moderatorID uint, action string) error {
+	model, err := moderationModel(targetType)
+	if err != nil {
+		return err
+	}
+	if err := db.First(model, targetID).Error; err != nil {
+		return err
+	}
+
+	reportStatus := models.ReportResolved
+	switch action {
+	case "hide":
+		err = db.Model(model).Update("status", models.ContentHidden).Error
+	case "restore":
+		err = db.Model(model).Update("status", models.ContentPublished).Error
+		reportStatus = models.ReportDismissed
+	case "delete":
+		err = db.Delete(model).Error
+	default:
+		return ErrUnknownTarget
+	}
+	if err != nil {
+		return err
+	}
+	if action == "restore" && targetType == "comment" {
+		// Одобренный после проверки комментарий: упоминания и ответ
+		// уведомляют только теперь
+		var c models.Comment
+		if db.First(&c, targetID).Error == nil {
+			NotifyCommentPublished(db, c)
+		}
+	}
+
+	now := time.Now()
+	return db.Model(&models.Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReportOpen).
+		Updates(map[string]interface{}{"status": reportStatus, "resolved_by_id": moderatorID, "resolved_at": &now}).Error
+}
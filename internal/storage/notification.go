@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// notifyHooks run after a notification is stored, e.g. to send it by e-mail.
+var notifyHooks []func(models.Notification)
+
+// OnNotify registers fn to be called for every stored notification. Hooks
+// are registered at startup and must not block.
+func OnNotify(fn func(models.Notification)) {
+	notifyHooks = append(notifyHooks, fn)
+}
+
+// Notify stores a notification. Users are not notified about their own
+// actions. Failures are logged and never block the caller.
+func Notify(db *gorm.DB, n models.Notification) {
+	if n.UserID == 0 || n.UserID == n.ActorID {
+		return
+	}
+	if err := db.Omit("Actor").Create(&n).Error; err != nil {
+		log.Printf("Notify %s: %v", n.Kind, err)
+		return
+	}
+	for _, fn := range notifyHooks {
+		fn(n)
+	}
+}
+
+// notifyEnrollmentStatus tells the learner their enrollment changed status.
+func notifyEnrollmentStatus(db *gorm.DB, enrollmentID uint, status string, actorID uint) {
+	var e models.Enrollment
+	if err := db.Preload("Course", func(db *gorm.DB) *gorm.DB { return db.Select("id, title") }).
+		First(&e, enrollmentID).Error; err != nil {
+		log.Printf("notifyEnrollmentStatus: %v", err)
+		return
+	}
+	Notify(db, models.Notification{
+		UserID:  e.UserID,
+		ActorID: actorID,
+		Kind:    models.NotifyEnrollment,
+		Subject: e.Course.Title,
+		Detail:  status,
+		Link:    fmt.Sprintf("/course/%d/learn", e.CourseID),
+	})
+}
+
+// Notifications returns a page of the user's notifications, newest first.
+func Notifications(db *gorm.DB, userID uint, unreadOnly bool, page, limit int) ([]models.Notification, int64, error) {
+	q := db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []models.Notification
+	err := q.Preload("Actor").Order("created_at desc").Order("id desc").
+		Limit(limit).Offset((page - 1) * limit).Find(&list).Error
+	return list, total, err
+}
+
+// UnreadNotifications counts the user's unread notifications.
+func UnreadNotifications(db *gorm.DB, userID uint) int64 {
+	var n int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&n)
+	return n
+}
+
+// MarkNotificationsRead marks the given notifications of the user as read;
+// with no ids it marks all of them.
+func MarkNotificationsRead(db *gorm.DB, userID uint, ids ...uint) error {
+	q := db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+	return q.Update("read_at", time.Now()).Error
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrNotAnAnswer is returned when accepting an answer that belongs to
+// another question.
+var ErrNotAnAnswer = errors.New("answer does not belong to the question")
+
+// IsCourseStaff reports whether the user is the course author or one of its
+// assistants.
+func IsCourseStaff(db *gorm.DB, userID, courseID uint) bool {
+	if userID == 0 {
+		return false
+	}
+	var n int64
+	db.Model(&models.Course{}).Where("id = ? AND author_id = ?", courseID, userID).Count(&n)
+	if n > 0 {
+		return true
+	}
+	db.Model(&models.CourseAssistant{}).Where("course_id = ? AND user_id = ?", courseID, userID).Count(&n)
+	return n > 0
+}
+
+// courseStaffIDs returns the author and assistants of a course.
+func courseStaffIDs(db *gorm.DB, courseID uint) map[uint]bool {
+	staff := make(map[uint]bool)
+	var course models.Course
+	if db.Select("author_id").First(&course, courseID).Error == nil {
+		staff[course.AuthorID] = true
+	}
+	var ids []uint
+	db.Model(&models.CourseAssistant{}).Where("course_id = ?", courseID).Pluck("user_id", &ids)
+	for _, id := range ids {
+		staff[id] = true
+	}
+	return staff
+}
+
+// ToggleQAVote adds the user's upvote to a question or answer, or removes
+// it if already given, and returns the new vote count.
+func ToggleQAVote(db *gorm.DB, userID uint, targetType string, targetID uint) (int, bool, error) {
+	var model interface{} = &models.Question{}
+	if targetType == "answer" {
+		model = &models.Answer{}
+	}
+
+	voted := false
+	var count int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).
+			Delete(&models.QAVote{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			voted = true
+			if err := tx.Create(&models.QAVote{UserID: userID, TargetType: targetType, TargetID: targetID}).Error; err != nil {
+				return err
+			}
+		}
+		tx.Model(&models.QAVote{}).Where("target_type = ? AND target_id = ?", targetType, targetID).Count(&count)
+		return tx.Model(model).Where("id = ?", targetID).Update("upvotes", count).Error
+	})
+	return int(count), voted, err
+}
+
+// CreateAnswer stores an answer and updates the question's answer count.
+func CreateAnswer(db *gorm.DB, a *models.Answer) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(a).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Question{}).Where("id = ?", a.QuestionID).
+			Update("answer_count", gorm.Expr("answer_count + 1")).Error
+	})
+}
+
+// AcceptAnswer marks an answer as the accepted one; answerID 0 clears it.
+func AcceptAnswer(db *gorm.DB, q *models.Question, answerID uint) error {
+	var accepted *uint
+	if answerID > 0 {
+		var n int64
+		db.Model(&models.Answer{}).Where("id = ? AND question_id = ?", answerID, q.ID).Count(&n)
+		if n == 0 {
+			return ErrNotAnAnswer
+		}
+		accepted = &answerID
+	}
+	if err := db.Model(&models.Question{}).Where("id = ?", q.ID).
+		Update("accepted_answer_id", accepted).Error; err != nil {
+		return err
+	}
+	q.AcceptedAnswerID = accepted
+	return nil
+}
+
+// QuestionFilter narrows a question listing.
+type QuestionFilter struct {
+	CourseIDs  []uint
+	LessonID   uint
+	Search     string // по заголовку, тексту вопроса и ответам
+	Unanswered bool   // без принятого ответа и без ответа автора/ассистента
+	Sort       string // "votes", "newest" (по умолчанию), "oldest"
+}
+
+// FindQuestions returns a page of questions with their authors and lessons.
+func FindQuestions(db *gorm.DB, f QuestionFilter, page, limit int) ([]models.Question, int64, error) {
+	q := db.Model(&models.Question{})
+	if f.CourseIDs != nil {
+		q = q.Where("questions.course_id IN ?", f.CourseIDs)
+	}
+	if f.LessonID > 0 {
+		q = q.Where("questions.lesson_id = ?", f.LessonID)
+	}
+	if f.Search != "" {
+		like := "%" + f.Search + "%"
+		q = q.Where(`(questions.title ILIKE ? OR questions.body ILIKE ? OR EXISTS (
+			SELECT 1 FROM answers a WHERE a.question_id = questions.id AND a.deleted_at IS NULL AND a.body ILIKE ?))`,
+			like, like, like)
+	}
+	if f.Unanswered {
+		q = q.Where(`questions.accepted_answer_id IS NULL AND NOT EXISTS (
+			SELECT 1 FROM answers a JOIN courses c ON c.id = questions.course_id
+			WHERE a.question_id = questions.id AND a.deleted_at IS NULL AND (a.user_id = c.author_id OR a.user_id IN (
+				SELECT ca.user_id FROM course_assistants ca WHERE ca.course_id = questions.course_id)))`)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	switch f.Sort {
+	case "votes":
+		q = q.Order("questions.upvotes desc").Order("questions.created_at desc")
+	case "oldest":
+		q = q.Order("questions.created_at asc")
+	default:
+		q = q.Order("questions.created_at desc")
+	}
+
+	var questions []models.Question
+	err := q.Preload("User").
+		Preload("Lesson", func(db *gorm.DB) *gorm.DB { return db.Select("id, title, module_id") }).
+		Limit(limit).Offset((page - 1) * limit).Find(&questions).Error
+	return questions, total, err
+}
+
+// MarkQuestionVotes sets Voted on the questions the user has upvoted.
+func MarkQuestionVotes(db *gorm.DB, userID uint, questions []models.Question) {
+	if userID == 0 || len(questions) == 0 {
+		return
+	}
+	ids := make([]uint, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+	voted := votedTargets(db, userID, "question", ids)
+	for i := range questions {
+		questions[i].Voted = voted[questions[i].ID]
+	}
+}
+
+// LoadAnswers fills q.Answers — accepted answer first, then by votes — with
+// the viewer's votes and staff markers.
+func LoadAnswers(db *gorm.DB, q *models.Question, userID uint) error {
+	if err := db.Preload("User").Where("question_id = ?", q.ID).
+		Order("upvotes desc").Order("created_at asc").Find(&q.Answers).Error; err != nil {
+		return err
+	}
+
+	ids := make([]uint, len(q.Answers))
+	for i, a := range q.Answers {
+		ids[i] = a.ID
+	}
+	voted := map[uint]bool{}
+	if userID > 0 && len(ids) > 0 {
+		voted = votedTargets(db, userID, "answer", ids)
+	}
+	staff := courseStaffIDs(db, q.CourseID)
+
+	for i := range q.Answers {
+		a := &q.Answers[i]
+		a.Voted = voted[a.ID]
+		a.ByStaff = staff[a.UserID]
+		a.Accepted = q.AcceptedAnswerID != nil && *q.AcceptedAnswerID == a.ID
+		if a.Accepted && i > 0 {
+			accepted := *a
+			copy(q.Answers[1:i+1], q.Answers[:i])
+			q.Answers[0] = accepted
+		}
+	}
+	return nil
+}
+
+func votedTargets(db *gorm.DB, userID uint, targetType string, ids []uint) map[uint]bool {
+	var targets []uint
+	db.Model(&models.QAVote{}).Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, targetType, ids).
+		Pluck("target_id", &targets)
+	out := make(map[uint]bool, len(targets))
+	for _, id := range targets {
+		out[id] = true
+	}
+	return out
+}
+
+// LessonCourseID resolves the course a lesson belongs to.
+func LessonCourseID(db *gorm.DB, lessonID uint) (uint, error) {
+	var courseID uint
+	err := db.Table("lessons").Select("modules.course_id").
+		Joins("JOIN modules ON modules.id = lessons.module_id").
+		Where("lessons.id = ? AND lessons.deleted_at IS NULL", lessonID).
+		Row().Scan(&courseID)
+	return courseID, err
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// CourseProgressPercent returns the share of the course's lessons the user
+// has completed, 0–100.
+func CourseProgressPercent(db *gorm.DB, userID, courseID uint) int {
+	var total int64
+	db.Model(&models.Lesson{}).
+		Joins("JOIN modules ON modules.id = lessons.module_id").
+		Where("modules.course_id = ? AND modules.deleted_at IS NULL", courseID).
+		Count(&total)
+	if total == 0 {
+		return 0
+	}
+
+	var done int64
+	db.Model(&models.LessonProgress{}).
+		Where("user_id = ? AND course_id = ? AND is_done = ?", userID, courseID, true).
+		Count(&done)
+	if done >= total {
+		return 100
+	}
+	return int(done * 100 / total)
+}
+
+// RatingSummary is the rating histogram of a course's published reviews.
+type RatingSummary struct {
+	Average   float64  `json:"average"`
+	Total     int64    `json:"total"`
+	Histogram [5]int64 `json:"histogram"` // [0] — одна звезда, [4] — пять
+}
+
+// CourseRatingSummary counts published reviews by rating.
+func CourseRatingSummary(db *gorm.DB, courseID uint) (RatingSummary, error) {
+	var rows []struct {
+		Rating int
+		N      int64
+	}
+	err := db.Model(&models.Review{}).Select("rating, count(*) AS n").
+		Where("course_id = ? AND status = ?", courseID, models.ContentPublished).
+		Group("rating").Scan(&rows).Error
+
+	var sum RatingSummary
+	var points int64
+	for _, row := range rows {
+		if row.Rating < 1 || row.Rating > 5 {
+			continue
+		}
+		sum.Histogram[row.Rating-1] = row.N
+		sum.Total += row.N
+		points += int64(row.Rating) * row.N
+	}
+	if sum.Total > 0 {
+		sum.Average = float64(points) / float64(sum.Total)
+	}
+	return sum, err
+}
+
+// FindReviews returns a page of a course's published reviews. sort is
+// "helpful", "rating_desc", "rating_asc" or "newest" (default).
+func FindReviews(db *gorm.DB, courseID uint, sort string, page, limit int) ([]models.Review, int64, error) {
+	q := db.Model(&models.Review{}).Where("course_id = ? AND status = ?", courseID, models.ContentPublished)
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	switch sort {
+	case "helpful":
+		q = q.Order("helpful_count desc")
+	case "rating_desc":
+		q = q.Order("rating desc")
+	case "rating_asc":
+		q = q.Order("rating asc")
+	}
+
+	var reviews []models.Review
+	err := q.Order("created_at desc").Preload("User").
+		Limit(limit).Offset((page - 1) * limit).Find(&reviews).Error
+	return reviews, total, err
+}
+
+// MarkHelpfulVotes sets Helpful on the reviews the user has marked.
+func MarkHelpfulVotes(db *gorm.DB, userID uint, reviews []models.Review) {
+	if userID == 0 || len(reviews) == 0 {
+		return
+	}
+	ids := make([]uint, len(reviews))
+	for i, r := range reviews {
+		ids[i] = r.ID
+	}
+	var voted []uint
+	db.Model(&models.ReviewVote{}).Where("user_id = ? AND review_id IN ?", userID, ids).Pluck("review_id", &voted)
+	set := make(map[uint]bool, len(voted))
+	for _, id := range voted {
+		set[id] = true
+	}
+	for i := range reviews {
+		reviews[i].Helpful = set[reviews[i].ID]
+	}
+}
+
+// ToggleReviewHelpful marks the review helpful for the user, or removes the
+// mark if already given, and returns the new count.
+func ToggleReviewHelpful(db *gorm.DB, userID, reviewID uint) (int, bool, error) {
+	voted := false
+	var count int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ? AND review_id = ?", userID, reviewID).Delete(&models.ReviewVote{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			voted = true
+			if err := tx.Create(&models.ReviewVote{UserID: userID, ReviewID: reviewID}).Error; err != nil {
+				return err
+			}
+		}
+		tx.Model(&models.ReviewVote{}).Where("review_id = ?", reviewID).Count(&count)
+		return tx.Model(&models.Review{}).Where("id = ?", reviewID).Update("helpful_count", count).Error
+	})
+	return int(count), voted, err
+}
+
+// SetReviewResponse stores the course author's public reply; an empty text
+// removes it.
+func SetReviewResponse(db *gorm.DB, review *models.Review, text string) error {
+	var at *time.Time
+	if text != "" {
+		now := time.Now()
+		at = &now
+	}
+	if err := db.Model(review).Updates(map[string]interface{}{
+		"response":     text,
+		"responded_at": at,
+	}).Error; err != nil {
+		return err
+	}
+	review.Response, review.RespondedAt = text, at
+	return nil
+}
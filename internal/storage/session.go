@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrSessionNotFound is returned when revoking a session of another user or
+// one that has already ended.
+var ErrSessionNotFound = errors.New("session not found")
+
+// LoadSession returns the live session of a raw cookie token.
+func LoadSession(db *gorm.DB, raw string) (models.Session, error) {
+	var s models.Session
+	err := db.Where("token_hash = ? AND expires_at > ?", hashToken(raw), time.Now()).First(&s).Error
+	return s, err
+}
+
+// CreateSession stores a new session of a raw cookie token together with
+// the device info.
+func CreateSession(db *gorm.DB, raw string, s models.Session) error {
+	s.TokenHash = hashToken(raw)
+	s.LastSeenAt = time.Now()
+	return db.Create(&s).Error
+}
+
+// UpdateSession saves the values of an existing session. A session revoked
+// in the meantime (logout everywhere, password reset) is not brought back:
+// the result is ErrSessionNotFound.
+func UpdateSession(db *gorm.DB, raw string, s models.Session) error {
+	res := db.Model(&models.Session{}).Where("token_hash = ?", hashToken(raw)).
+		Updates(map[string]interface{}{
+			"user_id":      s.UserID,
+			"data":         s.Data,
+			"last_seen_at": time.Now(),
+			"expires_at":   s.ExpiresAt,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// TouchSession records activity of the session for the device list.
+func TouchSession(db *gorm.DB, id uint) {
+	db.Model(&models.Session{}).Where("id = ?", id).Update("last_seen_at", time.Now())
+}
+
+// DeleteSession ends the session of a raw cookie token (logout).
+func DeleteSession(db *gorm.DB, raw string) error {
+	return db.Where("token_hash = ?", hashToken(raw)).Delete(&models.Session{}).Error
+}
+
+// UserSessions lists the live sessions of a user, most recently used
+// first; the one of currentRaw is marked Current.
+func UserSessions(db *gorm.DB, userID uint, currentRaw string) ([]models.Session, error) {
+	var list []models.Session
+	err := db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&list).Error
+	current := hashToken(currentRaw)
+	for i := range list {
+		list[i].Current = list[i].TokenHash == current
+	}
+	return list, err
+}
+
+// RevokeSession ends one session of the user, e.g. on a lost device.
+func RevokeSession(db *gorm.DB, userID, sessionID uint) error {
+	res := db.Where("id = ? AND user_id = ?", sessionID, userID).Delete(&models.Session{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions logs the user out everywhere. Sessions of the raw
+// tokens in keep stay alive.
+func RevokeUserSessions(db *gorm.DB, userID uint, keep ...string) error {
+	q := db.Where("user_id = ?", userID)
+	if len(keep) > 0 {
+		hashes := make([]string, len(keep))
+		for i, raw := range keep {
+			hashes[i] = hashToken(raw)
+		}
+		q = q.Where("token_hash NOT IN ?", hashes)
+	}
+	return q.Delete(&models.Session{}).Error
+}
+
+// CleanupSessions deletes expired sessions.
+func CleanupSessions(db *gorm.DB) error {
+	return db.Where("expires_at <= ?", time.Now()).Delete(&models.Session{}).Error
+}
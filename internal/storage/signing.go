@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBadToken         = errors.New("malformed certificate token")
+	ErrUnknownKey       = errors.New("certificate signed with an unknown key")
+	ErrKeyRevoked       = errors.New("signing key has been revoked")
+	ErrInvalidSignature = errors.New("signature does not match")
+	ErrSigningSecret    = errors.New("signing key cannot be decrypted, check SIGNING_KEY_SECRET")
+)
+
+// signingKeyLock is the advisory lock taken while the active key changes,
+// so that concurrent first signatures or rotations leave one active key.
+const signingKeyLock = 0x5167_4b65 // "QgKe"
+
+// seedAEAD encrypts the private seeds of signing keys at rest, totpAEAD
+// the TOTP secrets of users.
+var seedAEAD, totpAEAD cipher.AEAD
+
+// SetSigningSecret sets the secret (SIGNING_KEY_SECRET) whose derived keys
+// encrypt signing key seeds and TOTP secrets in the database.
+func SetSigningSecret(secret string) error {
+	seed, err := secretAEAD("certificate-signing-seed:", secret)
+	if err != nil {
+		return err
+	}
+	totp, err := secretAEAD("totp-secret:", secret)
+	if err != nil {
+		return err
+	}
+	seedAEAD, totpAEAD = seed, totp
+	return nil
+}
+
+// secretAEAD derives an AES-GCM key from the secret; the label keeps the
+// keys for different data apart.
+func secretAEAD(label, secret string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(label + secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSeed encrypts a seed; the key id is authenticated with it, so a
+// sealed seed cannot be moved to another key row.
+func sealSeed(kid string, seed []byte) ([]byte, error) {
+	if seedAEAD == nil {
+		return nil, ErrSigningSecret
+	}
+	nonce := make([]byte, seedAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return seedAEAD.Seal(nonce, nonce, seed, []byte(kid)), nil
+}
+
+func openSeed(key models.SigningKey) ([]byte, error) {
+	if seedAEAD == nil || len(key.SealedSeed) < seedAEAD.NonceSize() {
+		return nil, ErrSigningSecret
+	}
+	n := seedAEAD.NonceSize()
+	seed, err := seedAEAD.Open(nil, key.SealedSeed[:n], key.SealedSeed[n:], []byte(key.KeyID))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, ErrSigningSecret
+	}
+	return seed, nil
+}
+
+var b64 = base64.RawURLEncoding
+
+// CertificatePayload is the signed content of a certificate. It carries
+// everything an employer needs, so verification does not depend on our
+// database.
+type CertificatePayload struct {
+	Version  int       `json:"v"`
+	Code     string    `json:"code"`
+	Issuer   string    `json:"iss"`
+	Learner  Party     `json:"learner"`
+	Course   Party     `json:"course"`
+	IssuedAt time.Time `json:"issued_at"`
+	Grade    *int      `json:"grade"`
+}
+
+// Party identifies the learner or the course inside a payload.
+type Party struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Типы JWS (заголовок typ). Ключ у сертификатов и бейджей общий, поэтому
+// по typ проверка сертификата отличает свой токен от Verifiable Credential.
+const (
+	certificateTokenType = "JWT"
+	credentialTokenType  = "vc+jwt"
+)
+
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+	Jku string `json:"jku,omitempty"` // адрес JWKS для внешних проверяющих
+}
+
+// ActiveSigningKey returns the key used for new signatures, generating the
+// first one on demand.
+func ActiveSigningKey(db *gorm.DB) (models.SigningKey, error) {
+	var key models.SigningKey
+	err := db.Where("status = ?", models.SigningKeyActive).Order("id desc").First(&key).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, err
+	}
+	// Первый ключ: параллельные запросы ждут друг друга на блокировке, и
+	// ключ создаёт только первый из них
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLock).Error; err != nil {
+			return err
+		}
+		err := tx.Where("status = ?", models.SigningKeyActive).Order("id desc").First(&key).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			key, err = rotateSigningKey(tx)
+		}
+		return err
+	})
+	return key, err
+}
+
+// RotateSigningKey creates a new active key and retires the previous one.
+// Retired keys stay published so earlier certificates remain verifiable.
+func RotateSigningKey(db *gorm.DB) (models.SigningKey, error) {
+	var key models.SigningKey
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLock).Error; err != nil {
+			return err
+		}
+		var err error
+		key, err = rotateSigningKey(tx)
+		return err
+	})
+	return key, err
+}
+
+// rotateSigningKey does the rotation inside a transaction holding
+// signingKeyLock.
+func rotateSigningKey(tx *gorm.DB) (models.SigningKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	sum := sha256.Sum256(pub)
+	key := models.SigningKey{
+		KeyID:     hex.EncodeToString(sum[:8]),
+		PublicKey: pub,
+		Status:    models.SigningKeyActive,
+	}
+	if key.SealedSeed, err = sealSeed(key.KeyID, priv.Seed()); err != nil {
+		return key, err
+	}
+
+	if err := tx.Model(&models.SigningKey{}).Where("status = ?", models.SigningKeyActive).
+		Updates(map[string]interface{}{"status": models.SigningKeyRetired, "retired_at": time.Now()}).Error; err != nil {
+		return key, err
+	}
+	return key, tx.Create(&key).Error
+}
+
+// RevokeSigningKey marks a compromised key; its signatures stop verifying.
+// Revoking the active key rotates to a fresh one.
+func RevokeSigningKey(db *gorm.DB, kid string) error {
+	var key models.SigningKey
+	if err := db.Where("key_id = ?", kid).First(&key).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := db.Model(&key).Updates(map[string]interface{}{
+		"status":     models.SigningKeyRevoked,
+		"retired_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	if key.Status == models.SigningKeyActive {
+		_, err := RotateSigningKey(db)
+		return err
+	}
+	return nil
+}
+
+// SignCertificate builds the payload of a certificate, signs it with the
+// active key and stores the token on the certificate.
+func SignCertificate(db *gorm.DB, cert *models.Certificate, issuer string) error {
+	var user models.User
+	if err := db.Select("id, public_id, name").First(&user, cert.UserID).Error; err != nil {
+		return err
+	}
+	var course models.Course
+	if err := db.Select("id, title").First(&course, cert.CourseID).Error; err != nil {
+		return err
+	}
+	key, err := ActiveSigningKey(db)
+	if err != nil {
+		return err
+	}
+
+	payload := CertificatePayload{
+		Version:  1,
+		Code:     cert.Code,
+		Issuer:   issuer,
+		Learner:  Party{ID: user.PublicID, Name: user.Name},
+		Course:   Party{ID: fmt.Sprint(course.ID), Name: course.Title},
+		IssuedAt: cert.IssuedAt.UTC().Truncate(time.Second),
+		Grade:    cert.Grade,
+	}
+	token, err := signPayload(key, jwsHeader{Alg: "EdDSA", Kid: key.KeyID, Typ: certificateTokenType}, payload)
+	if err != nil {
+		return err
+	}
+
+	cert.Signed, cert.KeyID = token, key.KeyID
+	return db.Model(&models.Certificate{}).Where("id = ?", cert.ID).
+		Updates(map[string]interface{}{"signed": token, "key_id": key.KeyID}).Error
+}
+
+// SignCredentialJWT signs a Verifiable Credential in VC-JWT form with the
+// active key. jku points verifiers at the published keys; typ "vc+jwt"
+// keeps the credential from passing as a certificate token.
+func SignCredentialJWT(db *gorm.DB, claims interface{}, jku string) (string, error) {
+	key, err := ActiveSigningKey(db)
+	if err != nil {
+		return "", err
+	}
+	return signPayload(key, jwsHeader{Alg: "EdDSA", Kid: key.KeyID, Typ: credentialTokenType, Jku: jku}, claims)
+}
+
+// signPayload produces a compact JWS: header.payload.signature.
+func signPayload(key models.SigningKey, h jwsHeader, payload interface{}) (string, error) {
+	header, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	seed, err := openSeed(key)
+	if err != nil {
+		return "", err
+	}
+	input := b64.EncodeToString(header) + "." + b64.EncodeToString(body)
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), []byte(input))
+	return input + "." + b64.EncodeToString(sig), nil
+}
+
+// VerifyCertificateToken checks a compact JWS against the published keys and
+// returns its payload together with the key that signed it.
+func VerifyCertificateToken(db *gorm.DB, token string) (CertificatePayload, models.SigningKey, error) {
+	var payload CertificatePayload
+	var key models.SigningKey
+
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return payload, key, ErrBadToken
+	}
+	rawHeader, err1 := b64.DecodeString(parts[0])
+	rawBody, err2 := b64.DecodeString(parts[1])
+	sig, err3 := b64.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return payload, key, ErrBadToken
+	}
+
+	var header jwsHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil || header.Alg != "EdDSA" || header.Typ != certificateTokenType {
+		return payload, key, ErrBadToken
+	}
+	if err := db.Where("key_id = ?", header.Kid).First(&key).Error; err != nil {
+		return payload, key, ErrUnknownKey
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key.PublicKey), []byte(parts[0]+"."+parts[1]), sig) {
+		return payload, key, ErrInvalidSignature
+	}
+	if err := json.Unmarshal(rawBody, &payload); err != nil || payload.Code == "" {
+		return payload, key, ErrBadToken
+	}
+	if key.Status == models.SigningKeyRevoked {
+		return payload, key, ErrKeyRevoked
+	}
+	return payload, key, nil
+}
+
+// JWK is a public key in JSON Web Key form (RFC 8037).
+type JWK struct {
+	Kty       string     `json:"kty"`
+	Crv       string     `json:"crv"`
+	X         string     `json:"x"`
+	Kid       string     `json:"kid"`
+	Use       string     `json:"use"`
+	Alg       string     `json:"alg"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+// PublicKeys lists every key that has ever signed certificates, newest first.
+func PublicKeys(db *gorm.DB) ([]JWK, error) {
+	if _, err := ActiveSigningKey(db); err != nil {
+		return nil, err
+	}
+	var keys []models.SigningKey
+	if err := db.Order("id desc").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	out := make([]JWK, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, JWK{
+			Kty:       "OKP",
+			Crv:       "Ed25519",
+			X:         b64.EncodeToString(k.PublicKey),
+			Kid:       k.KeyID,
+			Use:       "sig",
+			Alg:       "EdDSA",
+			Status:    k.Status,
+			CreatedAt: k.CreatedAt,
+			RetiredAt: k.RetiredAt,
+		})
+	}
+	return out, nil
+}
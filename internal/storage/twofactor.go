@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s/onlineCourse/internal/auth"
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets at a time.
+const recoveryCodeCount = 10
+
+var (
+	ErrBadSecondFactor    = errors.New("wrong or already used code")
+	ErrTwoFactorEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotActive = errors.New("two-factor authentication is not enabled")
+	ErrTOTPSecret         = errors.New("TOTP secret cannot be decrypted, check SIGNING_KEY_SECRET")
+)
+
+// SealTOTPSecret encrypts a TOTP secret for storage. The user id is
+// authenticated with it, so a sealed secret cannot be moved to another
+// account.
+func SealTOTPSecret(userID uint, secret string) (string, error) {
+	if totpAEAD == nil {
+		return "", ErrTOTPSecret
+	}
+	nonce := make([]byte, totpAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := totpAEAD.Seal(nonce, nonce, []byte(secret), totpAAD(userID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openTOTPSecret(userID uint, sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || totpAEAD == nil || len(raw) < totpAEAD.NonceSize() {
+		return "", ErrTOTPSecret
+	}
+	n := totpAEAD.NonceSize()
+	secret, err := totpAEAD.Open(nil, raw[:n], raw[n:], totpAAD(userID))
+	if err != nil {
+		return "", ErrTOTPSecret
+	}
+	return string(secret), nil
+}
+
+func totpAAD(userID uint) []byte {
+	return []byte("user:" + strconv.FormatUint(uint64(userID), 10))
+}
+
+// EnableTwoFactor turns on TOTP once the user has proved the app works with
+// a code for the new secret, and returns fresh recovery codes. sealed is
+// the new secret as returned by SealTOTPSecret.
+func EnableTwoFactor(db *gorm.DB, userID uint, sealed, code string) ([]string, error) {
+	secret, err := openTOTPSecret(userID, sealed)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrBadSecondFactor
+	}
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.User{}).Where("id = ? AND NOT totp_enabled", userID).Updates(map[string]interface{}{
+			"totp_secret":    sealed,
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrTwoFactorEnabled
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// DisableTwoFactor turns TOTP off; it takes a valid second factor so that a
+// stolen session alone cannot do it.
+func DisableTwoFactor(db *gorm.DB, user models.User, code string) error {
+	if err := CheckSecondFactor(db, user, code); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a
+// second factor.
+func RegenerateRecoveryCodes(db *gorm.DB, user models.User, code string) ([]string, error) {
+	if err := CheckSecondFactor(db, user, code); err != nil {
+		return nil, err
+	}
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// RecoveryCodesLeft counts the unused recovery codes of a user.
+func RecoveryCodesLeft(db *gorm.DB, userID uint) int64 {
+	var n int64
+	db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n)
+	return n
+}
+
+// CheckSecondFactor accepts a current TOTP code or an unused recovery code.
+// Each is accepted once: the TOTP step and the recovery code are spent
+// atomically, so a code seen over the shoulder cannot be replayed.
+func CheckSecondFactor(db *gorm.DB, user models.User, code string) error {
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotActive
+	}
+	code = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+
+	// Секрет не расшифровался — войти можно резервным кодом
+	secret, err := openTOTPSecret(user.ID, user.TOTPSecret)
+	if err != nil {
+		log.Printf("CheckSecondFactor user #%d: %v", user.ID, err)
+	} else if step, ok := auth.ValidateTOTP(secret, code, time.Now()); ok {
+		res := db.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrBadSecondFactor
+		}
+		return nil
+	}
+
+	res := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(code)).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBadSecondFactor
+	}
+	return nil
+}
+
+// replaceRecoveryCodes issues a new set of codes like "k3m9x-7qh2p"; only
+// their hashes are stored.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	const alphabet = "abcdefghjkmnpqrstuvwxyz023456789" // 32 знака без o, 1, l, i: байт % 32 без перекоса
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		for j := range b {
+			b[j] = alphabet[int(b[j])%len(alphabet)]
+		}
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(string(b))}
+	}
+	return codes, tx.Create(&rows).Error
+}
@@ -2,40 +2,144 @@ package storage
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/s/onlineCourse/internal/auth"
 	"github.com/s/onlineCourse/internal/models"
 	"gorm.io/gorm"
 )
 
-// SaveUser finds a user by Google ID; if found, updates name/picture, otherwise creates.
-func SaveUser(db *gorm.DB, userInfo models.User) (uint, error) {
-	var existingUser models.User
-
-	result := db.Where("google_id = ?", userInfo.GoogleID).First(&existingUser)
+var (
+	ErrNoEmail           = errors.New("the provider did not share an email address")
+	ErrIdentityTaken     = errors.New("this provider account is linked to another user")
+	ErrLinkRequiresLogin = errors.New("an account with this email exists: log in to it first to link the provider")
+)
 
-	if result.Error == nil {
-		updates := map[string]interface{}{
-			"email":   userInfo.Email,
-			"name":    userInfo.Name,
-			"picture": userInfo.Picture,
+// SaveIdentity finds or creates the user behind an external (OpenID
+// Connect) login and returns its ID.
+//
+// The user is looked up by the linked identity, then — for Google — by the
+// GoogleID of accounts created before providers were linked, then by a
+// verified email. An unverified email never links to an existing account:
+// the user must log in to it first (currentUserID) and link the provider
+// from there. Pending course invitations for the email are claimed on the
+// way.
+func SaveIdentity(db *gorm.DB, provider string, id auth.Identity, currentUserID uint) (uint, error) {
+	var link models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", provider, id.Subject).First(&link).Error
+	if err == nil {
+		if currentUserID != 0 && link.UserID != currentUserID {
+			return 0, ErrIdentityTaken
 		}
-		// back-fill PublicID for users created before this field existed
-		if existingUser.PublicID == "" {
-			updates["public_id"] = uuid.NewString()
+		var user models.User
+		if err := db.First(&user, link.UserID).Error; err != nil {
+			return 0, err
 		}
-		db.Model(&existingUser).Updates(updates)
-		return existingUser.ID, nil
+		updateExternalProfile(db, &user, provider, id)
+		return user.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
 
-	} else if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		userInfo.RoleID = models.RoleUser
-		userInfo.PublicID = uuid.NewString()
-		if err := db.Create(&userInfo).Error; err != nil {
-			return 0, err
+	email := NormalizeEmail(id.Email)
+	var user models.User
+	switch {
+	case currentUserID != 0:
+		err = db.First(&user, currentUserID).Error
+	case provider == auth.GoogleName:
+		err = db.Where("google_id = ? AND google_id <> ''", id.Subject).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) && email != "" && id.EmailVerified {
+			user, err = FindUserByEmail(db, email)
 		}
-		return userInfo.ID, nil
+	case email != "" && id.EmailVerified:
+		user, err = FindUserByEmail(db, email)
+	default:
+		err = gorm.ErrRecordNotFound
+	}
 
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if email == "" {
+			return 0, ErrNoEmail
+		}
+		if _, err := FindUserByEmail(db, email); err == nil {
+			return 0, ErrLinkRequiresLogin
+		}
+		user = models.User{Email: email, Name: id.Name, Picture: id.Picture, EmailVerified: id.EmailVerified}
+		if user.Name == "" {
+			user.Name = email[:strings.Index(email, "@")]
+		}
+		if provider == auth.GoogleName {
+			user.GoogleID = id.Subject
+		}
+		if err := createUser(db, &user); err != nil {
+			return 0, err
+		}
+	} else if err != nil {
+		return 0, err
 	} else {
-		return 0, result.Error
+		updateExternalProfile(db, &user, provider, id)
+	}
+
+	if err := db.Create(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  id.Subject,
+		Email:    email,
+	}).Error; err != nil {
+		return 0, err
 	}
+	return user.ID, nil
+}
+
+// updateExternalProfile refreshes the name and picture from the provider
+// and confirms the address when the provider vouches for it.
+func updateExternalProfile(db *gorm.DB, user *models.User, provider string, id auth.Identity) {
+	updates := map[string]interface{}{}
+	if id.Name != "" {
+		updates["name"] = id.Name
+	}
+	if id.Picture != "" {
+		updates["picture"] = id.Picture
+	}
+	if provider == auth.GoogleName && user.GoogleID == "" {
+		updates["google_id"] = id.Subject
+	}
+	if !user.EmailVerified && id.EmailVerified && NormalizeEmail(id.Email) == NormalizeEmail(user.Email) {
+		updates["email_verified"] = true
+		// Пароль неподтверждённого аккаунта мог задать кто угодно — сбрасываем
+		updates["password_hash"] = ""
+		user.EmailVerified = true
+	}
+	// back-fill PublicID for users created before this field existed
+	if user.PublicID == "" {
+		updates["public_id"] = uuid.NewString()
+	}
+	if len(updates) > 0 {
+		db.Model(user).Updates(updates)
+	}
+	if user.EmailVerified {
+		ClaimPendingInvitations(db, *user)
+	}
+}
+
+// createUser is the one place new accounts are made, whatever the sign-in
+// method. Invitations are claimed only for a confirmed address.
+func createUser(db *gorm.DB, user *models.User) error {
+	user.RoleID = models.RoleUser
+	user.PublicID = uuid.NewString()
+	if err := db.Create(user).Error; err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		ClaimPendingInvitations(db, *user)
+	}
+	return nil
+}
+
+// NormalizeEmail lowercases and trims an address so that lookups match
+// however the user typed it.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
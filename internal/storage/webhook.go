@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/s/onlineCourse/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBadWebhook is returned for an invalid URL or event list.
+var ErrBadWebhook = errors.New("webhook needs an http(s) URL and at least one known event")
+
+// ErrWebhookAddress is returned for a URL pointing into the local network.
+var ErrWebhookAddress = errors.New("webhook URL must point to a public address")
+
+// carrierNAT (100.64.0.0/10) is shared provider space, as private as 10/8.
+var carrierNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicWebhookIP reports whether webhooks may be sent to ip: loopback,
+// private, link-local (cloud metadata at 169.254.169.254) and unspecified
+// addresses are refused, so a subscriber URL cannot reach internal services.
+func PublicWebhookIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || carrierNAT.Contains(ip))
+}
+
+// WebhookPayload is the JSON body posted to subscribers. ID is shared by
+// all deliveries of one event, so receivers can drop duplicates.
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewWebhook validates and stores a subscription with a fresh signing
+// secret. ownerID nil makes it platform-wide.
+func NewWebhook(db *gorm.DB, ownerID *uint, rawURL string, events []string) (models.Webhook, error) {
+	hook := models.Webhook{OwnerID: ownerID, Active: true}
+	if err := setWebhookFields(&hook, rawURL, events); err != nil {
+		return hook, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return hook, err
+	}
+	hook.Secret = hex.EncodeToString(b)
+	return hook, db.Create(&hook).Error
+}
+
+// UpdateWebhook changes the URL, events and active flag of a subscription.
+func UpdateWebhook(db *gorm.DB, hook *models.Webhook, rawURL string, events []string, active bool) error {
+	if err := setWebhookFields(hook, rawURL, events); err != nil {
+		return err
+	}
+	hook.Active = active
+	return db.Model(hook).Select("url", "events", "active").Updates(hook).Error
+}
+
+func setWebhookFields(hook *models.Webhook, rawURL string, events []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrBadWebhook
+	}
+	// Имена проверяются при каждой отправке (после DNS), здесь — только
+	// очевидные адреса, чтобы автор сразу увидел ошибку.
+	host := u.Hostname()
+	if ip := net.ParseIP(host); (ip != nil && !PublicWebhookIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookAddress
+	}
+
+	known := make(map[string]bool, len(models.WebhookEvents))
+	for _, e := range models.WebhookEvents {
+		known[e] = true
+	}
+	var list []string
+	seen := make(map[string]bool)
+	for _, e := range events {
+		if known[e] && !seen[e] {
+			seen[e] = true
+			list = append(list, e)
+		}
+	}
+	if len(list) == 0 {
+		return ErrBadWebhook
+	}
+
+	hook.URL, hook.Events = u.String(), list
+	return nil
+}
+
+// DispatchWebhook queues the event for every active webhook subscribed to
+// it: the platform-wide ones and, for course events, the course author's.
+// Failures are logged, never returned: webhooks must not block the action
+// that caused them.
+func DispatchWebhook(db *gorm.DB, event string, courseID uint, data interface{}) {
+	q := db.Where("active = ?", true)
+	var authorID uint
+	if courseID != 0 {
+		db.Model(&models.Course{}).Where("id = ?", courseID).Pluck("author_id", &authorID)
+	}
+	if authorID != 0 {
+		q = q.Where("owner_id IS NULL OR owner_id = ?", authorID)
+	} else {
+		q = q.Where("owner_id IS NULL")
+	}
+
+	var hooks []models.Webhook
+	if err := q.Find(&hooks).Error; err != nil {
+		log.Printf("DispatchWebhook %s: %v", event, err)
+		return
+	}
+
+	var payload []byte
+	now := time.Now()
+	queued := false
+	for _, hook := range hooks {
+		if !hook.Subscribed(event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(WebhookPayload{ID: uuid.NewString(), Event: event, CreatedAt: now, Data: data})
+			if err != nil {
+				log.Printf("DispatchWebhook %s: %v", event, err)
+				return
+			}
+		}
+		if err := db.Create(&models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		}).Error; err != nil {
+			log.Printf("DispatchWebhook %s: %v", event, err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		EnqueueJob(db, models.JobDeliverWebhooks, struct{}{}, JobOptions{MaxAttempts: 1})
+	}
+}
+
+// Redeliver queues the payload of a past delivery again as a new delivery.
+func Redeliver(db *gorm.DB, original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	now := time.Now()
+	d := models.WebhookDelivery{
+		WebhookID:     original.WebhookID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+		RedeliveryOf:  &original.ID,
+	}
+	if err := db.Create(&d).Error; err != nil {
+		return d, err
+	}
+	EnqueueJob(db, models.JobDeliverWebhooks, struct{}{}, JobOptions{MaxAttempts: 1})
+	return d, nil
+}
+
+// ClaimWebhookDeliveries picks up to limit due deliveries and pushes their
+// next attempt lease into the future, so that other instances skip them
+// while they are being sent.
+func ClaimWebhookDeliveries(db *gorm.DB, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	var due []models.WebhookDelivery
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, time.Now()).
+			Order("next_attempt_at").Limit(limit).Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(due))
+		for i, d := range due {
+			ids[i] = d.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(lease)).Error
+	})
+	return due, err
+}
+
+// --- события ---
+
+type webhookUser struct {
+	ID    uint   `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type webhookCourse struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+}
+
+func loadWebhookUser(db *gorm.DB, userID uint) webhookUser {
+	var u models.User
+	db.Select("id, email, name").First(&u, userID)
+	return webhookUser{ID: u.ID, Email: u.Email, Name: u.Name}
+}
+
+func loadWebhookCourse(db *gorm.DB, courseID uint) webhookCourse {
+	var c models.Course
+	db.Select("id, title").First(&c, courseID)
+	return webhookCourse{ID: c.ID, Title: c.Title}
+}
+
+// dispatchEnrollmentWebhook sends enrollment.created / enrollment.approved.
+func dispatchEnrollmentWebhook(db *gorm.DB, event string, enrollmentID uint) {
+	var e models.Enrollment
+	if err := db.First(&e, enrollmentID).Error; err != nil {
+		log.Printf("dispatchEnrollmentWebhook: %v", err)
+		return
+	}
+	DispatchWebhook(db, event, e.CourseID, map[string]interface{}{
+		"enrollment": map[string]interface{}{
+			"id":         e.ID,
+			"status":     e.Status,
+			"expires_at": e.ExpiresAt,
+		},
+		"user":   loadWebhookUser(db, e.UserID),
+		"course": loadWebhookCourse(db, e.CourseID),
+	})
+}
+
+// DispatchLessonCompleted sends lesson.completed.
+func DispatchLessonCompleted(db *gorm.DB, userID, courseID, lessonID uint) {
+	var lesson models.Lesson
+	db.Select("id, title").First(&lesson, lessonID)
+	DispatchWebhook(db, models.EventLessonCompleted, courseID, map[string]interface{}{
+		"lesson":       map[string]interface{}{"id": lesson.ID, "title": lesson.Title},
+		"user":         loadWebhookUser(db, userID),
+		"course":       loadWebhookCourse(db, courseID),
+		"progress":     CourseProgressPercent(db, userID, courseID),
+		"completed_at": time.Now(),
+	})
+}
+
+// DispatchCertificateIssued sends certificate.issued; verifyURL is the
+// public verification page of the certificate.
+func DispatchCertificateIssued(db *gorm.DB, cert models.Certificate, verifyURL string) {
+	DispatchWebhook(db, models.EventCertificateIssued, cert.CourseID, map[string]interface{}{
+		"certificate": map[string]interface{}{
+			"code":       cert.Code,
+			"issued_at":  cert.IssuedAt,
+			"grade":      cert.Grade,
+			"verify_url": verifyURL,
+		},
+		"user":   loadWebhookUser(db, cert.UserID),
+		"course": loadWebhookCourse(db, cert.CourseID),
+	})
+}
+
+// DispatchCourseApproved sends course.approved.
+func DispatchCourseApproved(db *gorm.DB, course models.Course) {
+	DispatchWebhook(db, models.EventCourseApproved, course.ID, map[string]interface{}{
+		"course": webhookCourse{ID: course.ID, Title: course.Title},
+		"author": loadWebhookUser(db, course.AuthorID),
+	})
+}